@@ -24,47 +24,72 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"io/fs"
 	"log"
-)
-
-var debug = false
+	"log/slog"
+	"math/rand"
+	"os"
+	"time"
 
-// Init config
+	"github.com/dzirg44/pingo2/pkg/monitor"
+)
 
 // Main function
 func main() {
 	//filename := flag.String("f", "config.toml", "TOML configuration file")
-	filename := flag.String("f", "config.json", "JSON configuration file")
+	filename := flag.String("f", "config.json", "JSON or YAML (.yaml, .yml) configuration file")
 	httpPort := flag.Int("p", 8888, "HTTP port")
-	flag.BoolVar(&debug, "d", false, "Enable debug output")
+	flag.BoolVar(&monitor.Debug, "d", false, "Enable debug output")
+	once := flag.Bool("once", false, "Check every target once without alerting, print the results and exit, non-zero if any is down")
+	testAlertsOnly := flag.Bool("test-alerts", false, "Send a test down and up alert for every target, then exit")
 
 	flag.Parse()
 
+	// randomize check offsets between runs
+	rand.Seed(time.Now().UnixNano())
+
 	// Config
 	log.Printf("Opening config file: %s\n", *filename)
-	config := readConfig(*filename)
-	log.Println("Config loaded")
-
-	// Running
-	res := make(chan TargetStatus)
-	state := NewState()
-
-	for _, target := range config.Targets {
-		if target.Addr != "" {
-			startTarget(target, res, config)
+	config, err := monitor.ReadConfig(*filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		if err := monitor.WriteDefaultConfig(*filename); err != nil {
+			log.Fatal(err)
 		}
+		log.Printf("Config file created: %s\n", *filename)
+		config, err = monitor.ReadConfig(*filename)
 	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := config.Validate(); err != nil {
+		log.Fatalf("Invalid config:\n%s", err)
+	}
+	if err := monitor.SetupLogging(config); err != nil {
+		log.Fatalf("Invalid config: %s", err)
+	}
+	slog.Info("config loaded", "file", *filename)
 
-	// HTTP
-	go startHttp(*httpPort, state)
-
-	for {
-		select {
-		case status := <-res:
-			state.Lock()
-			state.State[status.Target] = status
-			state.Unlock()
+	if *once || config.Once {
+		if !monitor.RunOnce(config) {
+			os.Exit(1)
+		}
+		return
+	}
+	if *testAlertsOnly {
+		if !monitor.TestAlerts(config) {
+			os.Exit(1)
 		}
+		return
+	}
+
+	listen := fmt.Sprintf(":%d", *httpPort)
+	if config.Listen != "" {
+		listen = config.Listen
+	}
+	if err := monitor.Serve(*filename, listen, config); err != nil {
+		log.Fatal(err)
 	}
 }
@@ -0,0 +1,50 @@
+package monitor
+
+import (
+	"sync"
+	"time"
+)
+
+// default seconds an acknowledgement lasts, see Alert.AckTTL
+const AckTTL = 24 * 60 * 60
+
+// Acknowledged outage, repeat down alerts are paused until the target
+// recovers or the ack expires.
+type Ack struct {
+	By   string
+	Time time.Time
+}
+
+// Acks of down targets, by id.
+var acks = struct {
+	sync.Mutex
+	ids map[int]Ack
+}{ids: make(map[int]Ack)}
+
+func setAck(id int, ack *Ack) {
+	acks.Lock()
+	defer acks.Unlock()
+	if ack != nil {
+		acks.ids[id] = *ack
+	} else {
+		delete(acks.ids, id)
+	}
+}
+
+// The target's ack, nil when there is none or it's older than ttl seconds.
+func ackOf(id int, ttl int) *Ack {
+	if ttl <= 0 {
+		ttl = AckTTL
+	}
+	acks.Lock()
+	defer acks.Unlock()
+	ack, ok := acks.ids[id]
+	if !ok {
+		return nil
+	}
+	if time.Since(ack.Time) > time.Duration(ttl)*time.Second {
+		delete(acks.ids, id)
+		return nil
+	}
+	return &ack
+}
@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Log to a buffer at debug level for the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func downStatus(t *Target) *TargetStatus {
+	now := time.Now()
+	return &TargetStatus{Target: t, State: "down", Since: now, LastCheck: now, ErrorMsg: "connection refused"}
+}
+
+func TestAlertLogFormatting(t *testing.T) {
+	buf := captureLog(t)
+	config := Config{Timeout: 5}
+	target := &Target{Id: 1, Name: "db", Addr: "tcp://db:5432", Commandrun: "true {{quote .Target.Name}}",
+		ToEmail: []string{"ops@example.com"}, AlertChannels: []string{"command"}}
+	alert(downStatus(target), config)
+
+	out := buf.String()
+	for _, bad := range []string{"%!(EXTRA", "%!s(MISSING)", "%!d(", "%!v("} {
+		if strings.Contains(out, bad) {
+			t.Errorf("malformed log line, %s in:\n%s", bad, out)
+		}
+	}
+	if !strings.Contains(out, "command=\"true 'db'\"") {
+		t.Errorf("command branch doesn't log the command:\n%s", out)
+	}
+	if strings.Contains(out, "event=alert_sent channel=command to=") {
+		t.Errorf("command branch logs the email recipient:\n%s", out)
+	}
+}
+
+func TestAlertCommandrun(t *testing.T) {
+	captureLog(t)
+	config := Config{Timeout: 5}
+	tests := []struct {
+		command string
+		ok      bool
+	}{
+		{"true", true},
+		{"false", false},
+	}
+	for _, tt := range tests {
+		target := &Target{Id: 1, Name: "cmd", Addr: "tcp://cmd:1", Commandrun: tt.command}
+		if ok := alert(downStatus(target), config); ok != tt.ok {
+			t.Errorf("Commandrun %q: alert ok %v, want %v", tt.command, ok, tt.ok)
+		}
+	}
+
+	// the command really runs, with the status filled in
+	out := filepath.Join(t.TempDir(), "out")
+	target := &Target{Id: 2, Name: "web 1", Addr: "tcp://web:80", Commandrun: "echo {{quote .Target.Name}} {{.State}} > " + out}
+	if !alert(downStatus(target), config) {
+		t.Fatal("alert command failed")
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "web 1 down\n" {
+		t.Errorf("command wrote %q", got)
+	}
+}
@@ -0,0 +1,38 @@
+package monitor
+
+import "time"
+
+type BreakerConfig struct {
+	// Seconds a target must be down before its interval is stretched,
+	// 0 disables the breaker
+	After int
+	// Multiply the interval by this on every check while still down,
+	// defaults to 2
+	Factor float64
+	// Longest interval in seconds, defaults to 10 times Target.Interval
+	MaxInterval int
+}
+
+// The check interval following one of the given length, for a target
+// down since since. Stretched while the breaker is open, base otherwise.
+func (b BreakerConfig) next(prev, base time.Duration, online bool, since time.Time) time.Duration {
+	if b.After <= 0 || online || time.Since(since) < time.Duration(b.After)*time.Second {
+		return base
+	}
+	factor := b.Factor
+	if factor <= 1 {
+		factor = 2
+	}
+	max := time.Duration(b.MaxInterval) * time.Second
+	if max <= 0 {
+		max = 10 * base
+	}
+	next := time.Duration(float64(prev) * factor)
+	if next > max {
+		next = max
+	}
+	if next < base {
+		next = base
+	}
+	return next
+}
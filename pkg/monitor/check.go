@@ -0,0 +1,1213 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// minimum interval between checks. Used as default value when none set by user.
+const CheckInterval = 30
+
+// don't alert if host goes down and comes back within this time span
+const StandoffInterval = 60
+
+// replaces secrets in output
+const redacted = "********"
+
+// delay between confirmation checks, in seconds
+const RetryDelay = 5
+
+// spread repeat alerts by up to this many percent of the alert interval
+const AlertJitter = 10
+
+// ping: percentage of lost packets tolerated by default, see Target.PingMaxLoss
+const PingMaxLoss = 50
+
+// http(s) response bytes read by default, see Config.MaxBodyBytes
+const MaxBodyBytes = 4 << 20
+
+type Target struct {
+	// target id
+	Id int
+	// Name of the Target
+	Name string
+	// Address of the target e.g. "http://localhost"
+	Addr string
+	// HTTP 'Host:' header (if different from Addr)
+	Host string
+	// Polling interval, in seconds
+	Interval int
+	// Cron expression e.g. "0 9 * * 1-5", used instead of Interval when set
+	Schedule string
+	// HTTP method, defaults to GET. HEAD skips reading the body
+	Method string
+	// Request body, e.g. for POST or PUT
+	Body string
+	// Extra request headers, e.g. Authorization or Accept
+	Headers map[string]string
+	// HTTP basic auth credentials, http(s) only. Never logged or shown
+	Username string
+	Password string
+	// http(s): proxy URL, "http://", "https://" or "socks5://", optionally
+	// with credentials. Overrides Config.Proxy when set, without either
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY apply
+	Proxy string
+	// https: warn when the certificate expires within this many days,
+	// overrides Config.CertWarnDays when set
+	CertWarnDays int
+	// https: PEM client certificate and key files, for servers that
+	// require client authentication
+	ClientCert string
+	ClientKey  string
+	// https: accept any server certificate. INSECURE, only meant for
+	// self-signed internal endpoints
+	InsecureSkipVerify bool
+	// http(s): keep connections open between checks, overrides
+	// Config.ReuseConnections when set
+	ReuseConnections *bool
+	// http(s): User-Agent header, overrides Config.UserAgent when set
+	UserAgent string
+	// Look for this string in the response body. For dns targets, an
+	// address that must be among the results
+	Keyword string
+	// Keyword is a regular expression
+	KeywordRegex bool
+	// http(s): more strings to look for in the response body, on top of
+	// Keyword
+	Keywords []string
+	// "all" (default) requires every one of Keywords, "any" at least one
+	KeywordMode string
+	// Fail if this string is found in the response body
+	AntiKeyword string
+	// http(s): charset the body is decoded from before keywords are
+	// matched, e.g. "shift_jis". Defaults to the charset of the
+	// Content-Type header, the body is matched as is without one
+	Charset string
+	// Accepted HTTP status codes, defaults to any 2xx or 3xx
+	ExpectStatus []int
+	// Response headers that must be present, each containing the given
+	// value. Names are case insensitive, an empty value only requires
+	// the header
+	ExpectHeaders map[string]string
+	// Follow HTTP redirects, defaults to true
+	FollowRedirects *bool
+	// Network timeout in seconds, overrides Config.Timeout when set
+	Timeout int
+	// Also send alert emails for this target to these addresses
+	ToEmail []string
+	// Email subject and body templates for this target's alerts, override
+	// Alert.SubjectTemplate and Alert.BodyTemplate, e.g.
+	// "[{{.Target.Name}}] {{if .Online}}up{{else}}down{{end}}"
+	SubjectTemplate string
+	BodyTemplate    string
+	// Run specific  command. Template actions are expanded with the status,
+	// e.g. "notify.sh {{quote .Target.Name}} {{.Online}}"
+	Commandrun string
+	// Expected SHA-256 of the response body, hex encoded in either case
+	ExpectHash string
+	// Collapse whitespace in the body before hashing
+	NormalizeBody bool
+	// Record the body hash on the first check when ExpectHash is empty
+	LearnHash bool
+	// http(s): alert whenever the body hash differs from the previous
+	// check, while staying up. Keyword is ignored
+	WatchChanges bool
+	// Maintenance windows for this target, on top of Config.Maintenance
+	Maintenance []Window
+	// Transitions to alert on: "down", "up", "cert", "changed", "degraded".
+	// Defaults to all but degraded
+	NotifyOn []string
+	// Hold alerts during Config.QuietHours, they're sent as one summary
+	// when the quiet hours end
+	RespectQuietHours bool
+	// Ids of targets this one sits behind, e.g. its load balancer. Its
+	// alerts are suppressed while one of them is down
+	DependsOn []int
+	// Free form labels, e.g. {"env": "prod", "team": "web"}. Exported
+	// as Prometheus labels, usable as status API filters and to route
+	// alerts, see Alert.Routes
+	Tags map[string]string
+	// Notifiers used for this target: "command", "email", "slack",
+	// "teams", "discord", "webhook", "pagerduty", "sms". Defaults to all
+	// configured ones
+	AlertChannels []string
+	// Notifiers used for recoveries instead of AlertChannels. Also sends
+	// recoveries when Alert.NotifyRecovery is off
+	RecoveryChannels []string
+	// Warn when a check takes longer than this many milliseconds: the
+	// target stays up with State "warn", and alerts if NotifyOn has
+	// "degraded"
+	LatencyThreshold int
+	// Consecutive failed checks before the target is considered down,
+	// overrides Config.Retries when set
+	Retries int
+	// Extra checks that must succeed before a down target is considered up
+	ConfirmRecovery int
+	// Consecutive successful checks, at the normal interval, before a down
+	// target is considered up. Overrides Config.RecoveryThreshold when set
+	RecoveryThreshold int
+	// Seconds between confirmation checks, defaults to 5
+	RetryDelay int
+	// ping: echo requests sent per check, defaults to 1
+	PingCount int
+	// ping: payload bytes per echo request
+	PingSize int
+	// ping: down once more than this percentage of requests got no
+	// reply, defaults to 50. 0 tolerates no loss at all
+	PingMaxLoss *float64
+	// ws(s): after the handshake, send a ping frame and wait for the pong
+	WebSocketPing bool
+	// grpc(s): service asked about in the health check, the whole server
+	// when empty
+	GRPCService string
+	// exec: bash command run as the check, down unless it exits with 0.
+	// Its output becomes the error message
+	CheckCommand string
+	// file: down when the file wasn't modified for this many seconds
+	MaxAge int
+	// file: down when the file is smaller than this many bytes
+	MinSize int64
+	// tcp, udp: payload sent to the target
+	Send string
+	// tcp: the reply must contain this string, e.g. "+PONG"
+	Expect string
+	// udp: minimum reply size in bytes for the target to be up, defaults to 1
+	MinBytes int
+	// dns: resolver to query, "address:port". Defaults to Config.Resolver
+	// or the system resolver
+	Resolver string
+	// srv: number of endpoints that must be up, 0 means all
+	Quorum int
+	// srv: resolve the SRV record once at start instead of every poll
+	ResolveOnce bool
+
+	// srv records, cached when ResolveOnce is set
+	srvAddrs []*net.SRV
+	// compiled Keyword, when KeywordRegex is set
+	keywordRe  *regexp.Regexp
+	keywordErr error
+	// status stored before a restart, see Config.Database
+	restored *TargetStatus
+	// Config.Resolver, nil for the system resolver
+	resolver *net.Resolver
+	// the Config.NetworkCheck target
+	canary bool
+	// down alert suppressed by DependsOn, so the recovery isn't alerted
+	// either
+	suppressedDown bool
+	// http(s): kept between checks with ReuseConnections
+	transport *http.Transport
+	// http(s): delay asked for by a Retry-After header, taken before the
+	// next check
+	retryAfter time.Duration
+}
+
+type TargetStatus struct {
+	Target *Target
+	Online bool
+	// "up", "warn" when up but slower than Target.LatencyThreshold,
+	// "down", or "error" when the target can't be checked as configured
+	State     string
+	ErrorMsg  string
+	Since     time.Time
+	LastCheck time.Time
+	LastAlert time.Time
+	// kind of network error behind ErrorMsg: "timeout",
+	// "connection_refused", "dns", "tls" or "other", "config" for State
+	// "error"
+	FailureClass string `json:",omitempty"`
+	// http(s): start of the response body of a failed check, see
+	// Config.CaptureFailureBody
+	FailureBody string `json:",omitempty"`
+	// duration of the last check, average round trip for ping
+	Latency time.Duration
+	// in a maintenance window, alerts are suppressed
+	Maintenance bool
+	// going up and down too often, alerts are suppressed
+	Flapping bool
+	// disabled through the HTTP API, not checked until enabled
+	Disabled bool `json:",omitempty"`
+	// outage acknowledged through the HTTP API, repeat down alerts are
+	// paused until the target recovers or Alert.AckTTL passes
+	Ack *Ack `json:",omitempty"`
+	// id of a DependsOn target that is down, alerts are suppressed
+	SuppressedBy int `json:",omitempty"`
+	// latest error messages, oldest first, see Config.ErrorHistory
+	Errors []TimedError `json:",omitempty"`
+	// percentage of successful checks, keyed by window e.g. "24h0m0s"
+	Uptime map[string]float64
+	// https: expiry of the server certificate
+	CertExpiry time.Time
+	// https: certificate expires within CertWarnDays
+	CertWarning bool
+	// WatchChanges: the body differs from the previous check
+	ContentChanged bool
+	// ping: percentage of echo requests lost in the last check
+	PacketLoss float64
+	// SHA-256 of the last response body, when ExpectHash, LearnHash or WatchChanges is set
+	BodyHash string
+	// per-endpoint breakdown for srv targets
+	Endpoints []EndpointStatus
+	// went from up to warn in this check
+	degraded bool
+	// body hash recorded by Target.LearnHash, kept with the runner's status
+	// since the Target is shared with the status server
+	learnedHash string
+}
+
+func startTarget(ctx context.Context, wg *sync.WaitGroup, t Target, res chan TargetStatus, config Config) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runTarget(ctx, wg, t, res, config)
+	}()
+}
+
+func runTarget(ctx context.Context, wg *sync.WaitGroup, t Target, res chan TargetStatus, config Config) {
+	var err error
+	var failed bool
+	var addrURL *url.URL
+	tlog := targetLog(&t)
+	tlog.Info("starting target", "event", "start", "name", t.Name)
+	if t.Interval < CheckInterval {
+		t.Interval = CheckInterval
+	}
+	if t.Timeout < 0 {
+		t.Timeout = 0
+	}
+	if t.RetryDelay <= 0 {
+		t.RetryDelay = RetryDelay
+	}
+	if t.CertWarnDays == 0 {
+		t.CertWarnDays = config.CertWarnDays
+	}
+	if t.Proxy == "" {
+		t.Proxy = config.Proxy
+	}
+	if t.UserAgent == "" {
+		t.UserAgent = config.UserAgent
+	}
+	if t.ReuseConnections == nil {
+		t.ReuseConnections = &config.ReuseConnections
+	}
+	if t.UserAgent == "" {
+		t.UserAgent = "pingo2/" + Version
+	}
+	t.resolver = configResolver(config)
+	if t.KeywordRegex && t.Keyword != "" {
+		if _, err := t.keywordRegexp(); err != nil {
+			tlog.Error("invalid keyword regex", "event", "config_error", "error", err)
+		}
+	}
+
+	// a target that can't be checked reports why once, rather than
+	// silently not running
+	configError := func(msg string) {
+		now := time.Now()
+		status := TargetStatus{Target: &t, State: "error", Since: now, LastCheck: now, FailureClass: "config", ErrorMsg: "config error, " + msg}
+		logTransition(&status, "")
+		if config.Alert.NotifyConfigErrors && !config.Once {
+			alert(&status, config)
+		}
+		select {
+		case res <- status:
+		case <-ctx.Done():
+		}
+	}
+
+	addrURL, err = url.Parse(t.Addr)
+	if err != nil {
+		tlog.Error("target address could not be read", "event", "config_error", "error", err)
+		configError(fmt.Sprintf("address could not be read, %s", err))
+		return
+	}
+	if !schemes[addrURL.Scheme] {
+		tlog.Error("unsupported scheme", "event", "config_error", "scheme", addrURL.Scheme)
+		configError(fmt.Sprintf("unsupported scheme '%s'", addrURL.Scheme))
+		return
+	}
+	if config.Standoff == 0 {
+		config.Standoff = StandoffInterval
+	} else if config.Standoff <= t.Interval {
+		tlog.Warn("Standoff can't be <= Interval", "event", "config_error", "standoff", config.Standoff, "interval", t.Interval, "new_standoff", t.Interval+1)
+		config.Standoff = t.Interval + 1
+	}
+
+	if !config.Once {
+		// see healthzHandler
+		defer unscheduleCheck(&t)
+	}
+	var sched cron.Schedule
+	if t.Schedule != "" {
+		sched, err = cron.ParseStandard(t.Schedule)
+		if err != nil {
+			tlog.Error("schedule could not be read", "event", "config_error", "schedule", t.Schedule, "error", err)
+			configError(fmt.Sprintf("schedule could not be read, %s", err))
+			return
+		}
+	} else {
+		// wait a bit, to randomize check offset
+		if !config.Once {
+			offset := checkOffset(t.Interval)
+			scheduleCheck(&t, time.Now().Add(offset), config)
+			if !sleep(ctx, offset) {
+				return
+			}
+		}
+	}
+	// when the next check is due, see Config.Jitter
+	interval := time.Duration(t.Interval) * time.Second
+	next := time.Now()
+	if config.Alert.Jitter == 0 {
+		config.Alert.Jitter = AlertJitter
+	}
+	// repeat alert interval, backs off while the target stays down
+	backoff := time.Second * time.Duration(config.Alert.Interval)
+	realert := jitter(backoff, config.Alert.Jitter)
+
+	var alertRequest chan TargetStatus
+	// when the alert routine last sent an alert
+	var alerted chan time.Time
+	if !config.Once {
+		alertRequest = make(chan TargetStatus, 1)
+		alerted = make(chan time.Time, 1)
+		// spawn routine to handle alert requests
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			alertRoutine(ctx, alertRequest, alerted, config)
+		}()
+	}
+	status := TargetStatus{Target: &t, Online: true, State: "up", Since: time.Now()}
+	if t.restored != nil {
+		// carry on where we left off, so a known outage isn't alerted again
+		status.Online = t.restored.Online
+		status.Since = t.restored.Since
+		status.LastAlert = t.restored.LastAlert
+		status.ErrorMsg = t.restored.ErrorMsg
+		if !status.Online {
+			status.State = "down"
+		}
+		t.restored = nil
+	}
+
+	// consecutive failed and successful checks
+	fails, passes := 0, 0
+	retries := t.Retries
+	if retries <= 0 {
+		retries = config.Retries
+	}
+	recovery := t.RecoveryThreshold
+	if recovery <= 0 {
+		recovery = config.RecoveryThreshold
+	}
+	if config.Once {
+		// the one check decides
+		retries, recovery = 0, 0
+	}
+
+	// certificate warning already alerted
+	certWarned := false
+	// WatchChanges baseline
+	lastHash := ""
+	flap := flapDetector{FlapConfig: config.Flap}
+
+	// waiting for the next check, scheduled targets wait before checking.
+	// Each interval is spread anew, so targets don't drift back into step
+	// interval after the last check, see Config.Breaker
+	current := interval
+	waitNext := func() bool {
+		if sched != nil {
+			return true
+		}
+		stretched := config.Breaker.next(current, interval, status.Online, status.Since)
+		if stretched != current && stretched != interval {
+			tlog.Debug("down for long, checking less often", "event", "breaker", "interval", stretched)
+		} else if stretched == interval && current != interval {
+			tlog.Info("back to the normal interval", "event", "breaker", "interval", interval)
+		}
+		current = stretched
+		next = next.Add(jitter(current, config.Jitter))
+		if now := time.Now(); next.Before(now) {
+			// the check took longer than the interval
+			next = now
+		}
+		scheduleCheck(&t, next, config)
+		if !sleep(ctx, time.Until(next)) {
+			tlog.Info("stopped", "event", "stop")
+			return false
+		}
+		return true
+	}
+
+	for {
+		if sched != nil && !config.Once {
+			at := sched.Next(time.Now())
+			scheduleCheck(&t, at, config)
+			if !sleep(ctx, time.Until(at)) {
+				return
+			}
+		}
+		if isDisabled(t.Id) && !config.Once {
+			tlog.Debug("check skipped, disabled", "event", "check_skipped")
+			if !waitNext() {
+				return
+			}
+			continue
+		}
+
+		from := status.State
+		status.ErrorMsg = ""
+		status.FailureClass = ""
+		status.CertWarning = false
+		status.ContentChanged = false
+		status.degraded = false
+		status.FailureBody = ""
+
+		// Polling
+		if !acquireSlot(ctx) {
+			return
+		}
+		failed = probe(&t, addrURL, &status, config)
+		releaseSlot()
+
+		// was offline, confirm the recovery before believing it
+		for i := 0; !failed && !status.Online && i < t.ConfirmRecovery; i++ {
+			if !sleep(ctx, time.Duration(t.RetryDelay)*time.Second) || !acquireSlot(ctx) {
+				return
+			}
+			failed = probe(&t, addrURL, &status, config)
+			releaseSlot()
+			if failed {
+				tlog.Debug("recovery not confirmed", "event", "check_failed", "checks", i+1)
+			}
+		}
+
+		status.LastCheck = time.Now()
+		select {
+		case at := <-alerted:
+			status.LastAlert = at
+		default:
+		}
+		status.Maintenance = inMaintenance(status.LastCheck, config.Maintenance, t.Maintenance)
+
+		tlog.Debug("checked", "event", "check", "failed", failed, "online", status.Online, "since", status.Since, "last_alert", status.LastAlert, "last_check", status.LastCheck)
+
+		if failed {
+			fails++
+			passes = 0
+		} else {
+			fails = 0
+			passes++
+		}
+
+		if failed {
+			// Error during connect
+			if status.Online && fails < retries {
+				// was online, wait for more failures before calling it down
+				tlog.Debug("check failed, retrying before going down", "event", "check_failed", "fails", fails, "retries", retries)
+			} else if status.Online {
+				// was online, now offline
+				status.Online = false
+				status.Since = time.Now()
+				if flap.transition(status.Since) {
+					tlog.Info("down alert NOT sent, flapping", "event", "alert_skipped")
+				} else {
+					requestAlert(ctx, alertRequest, status)
+				}
+				backoff = time.Second * time.Duration(config.Alert.Interval)
+				realert = jitter(backoff, config.Alert.Jitter)
+
+			} else {
+				// was offline, still offline
+				if status.Ack = ackOf(t.Id, config.Alert.AckTTL); status.Ack != nil && time.Since(status.LastAlert) > realert {
+					tlog.Debug("repeat alert NOT sent, acknowledged", "event", "alert_skipped", "by", status.Ack.By)
+				} else if !flap.flapping && time.Since(status.LastAlert) > realert {
+					requestAlert(ctx, alertRequest, status)
+					backoff = nextBackoff(backoff, config.Alert.MaxInterval)
+					realert = jitter(backoff, config.Alert.Jitter)
+				}
+			}
+		} else {
+			// Connect ok
+			if !status.Online && passes < recovery {
+				// was offline, wait for more successes before calling it up
+				tlog.Debug("check passed, waiting before going up", "event", "check_passed", "passes", passes, "threshold", recovery)
+			} else if !status.Online {
+				// was offline, now online
+				status.Online = true
+				if status.Ack != nil || ackOf(t.Id, config.Alert.AckTTL) != nil {
+					tlog.Info("acknowledgement cleared, back up", "event", "ack_cleared")
+				}
+				status.Ack = nil
+				setAck(t.Id, nil)
+				tlog.Debug("was offline, now online", "event", "up", "down_for", time.Since(status.Since))
+				if flap.transition(time.Now()) {
+					tlog.Info("up alert NOT sent, flapping", "event", "alert_skipped")
+				} else {
+					requestAlert(ctx, alertRequest, status)
+				}
+			}
+		}
+		// retried failures keep the state they had
+		switch {
+		case !status.Online:
+			status.State = "down"
+		case failed:
+		case t.slow(status.Latency):
+			if status.State == "up" {
+				status.degraded = true
+				tlog.Info("degraded, slow response", "event", "degraded", "latency", status.Latency)
+				requestAlert(ctx, alertRequest, status)
+			}
+			status.State = "warn"
+		default:
+			status.State = "up"
+		}
+		if status.State != from {
+			logTransition(&status, from)
+		}
+		setDown(t.Id, !status.Online)
+		status.SuppressedBy = 0
+		if !status.Online {
+			status.SuppressedBy = t.downDependency()
+		}
+
+		// stable again after flapping, alert the state it settled in
+		if flap.settle(time.Now()) {
+			tlog.Info("no longer flapping", "event", "flap_end")
+			status.Flapping = false
+			requestAlert(ctx, alertRequest, status)
+		}
+		status.Flapping = flap.flapping
+
+		// up but the certificate is about to expire, alert once
+		if status.Online && status.CertWarning && !certWarned {
+			requestAlert(ctx, alertRequest, status)
+		}
+		certWarned = status.CertWarning
+
+		// up but the content changed, alert every change
+		if t.WatchChanges && !failed {
+			if lastHash != "" && status.BodyHash != lastHash {
+				status.ContentChanged = true
+				if status.ErrorMsg == "" {
+					status.ErrorMsg = "content changed since the last check"
+				}
+				tlog.Warn("content changed", "event", "content_changed", "hash", status.BodyHash, "previous", lastHash)
+				requestAlert(ctx, alertRequest, status)
+			}
+			lastHash = status.BodyHash
+		}
+
+		// may have been disabled during the check
+		status.Disabled = isDisabled(t.Id)
+		select {
+		case res <- status:
+		case <-ctx.Done():
+			return
+		}
+		if config.Once {
+			return
+		}
+
+		// the target asked for a break, on top of the interval
+		if t.retryAfter > 0 {
+			wait := t.retryAfter
+			if sched == nil {
+				wait -= interval
+			}
+			t.retryAfter = 0
+			if wait > 0 {
+				tlog.Info("backing off, Retry-After", "event", "backoff", "delay", wait)
+				scheduleCheck(&t, time.Now().Add(wait), config)
+				if !sleep(ctx, wait) {
+					return
+				}
+			}
+		}
+
+		if !waitNext() {
+			return
+		}
+	}
+}
+
+// Bounds the number of checks in flight, see Config.MaxConcurrency.
+// Unlimited when nil.
+var checkSlots chan struct{}
+
+func limitConcurrency(config Config) {
+	if config.MaxConcurrency > 0 {
+		checkSlots = make(chan struct{}, config.MaxConcurrency)
+	}
+}
+
+// Wait for a free check slot, returns false if ctx got cancelled meanwhile.
+func acquireSlot(ctx context.Context) bool {
+	if checkSlots == nil {
+		return true
+	}
+	select {
+	case checkSlots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func releaseSlot() {
+	if checkSlots != nil {
+		<-checkSlots
+	}
+}
+
+// Sleep for d, returns false if ctx got cancelled meanwhile.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Hand a copy of status to the alert routine, unless shutting down. The
+// check goes on changing its own while the alert may be held.
+func requestAlert(ctx context.Context, alertRequest chan<- TargetStatus, status TargetStatus) {
+	if alertRequest == nil {
+		// Once mode
+		return
+	}
+	select {
+	case alertRequest <- status:
+	case <-ctx.Done():
+	}
+}
+
+// Double the repeat alert interval up to maxInterval seconds. Without a
+// maxInterval, the interval stays fixed.
+func nextBackoff(d time.Duration, maxInterval int) time.Duration {
+	max := time.Duration(maxInterval) * time.Second
+	if d >= max {
+		return d
+	}
+	d *= 2
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// Randomly spread d by up to ±pct percent, so targets that went down together
+// don't keep firing at the same instant.
+func jitter(d time.Duration, pct int) time.Duration {
+	if pct <= 0 || d <= 0 {
+		return d
+	}
+	if pct > 100 {
+		pct = 100
+	}
+	spread := int64(d) * int64(pct) / 100
+	if spread == 0 {
+		return d
+	}
+	return d + time.Duration(rand.Int63n(2*spread+1)-spread)
+}
+
+// Random delay before a target's first check, under its interval in
+// seconds, so targets with the same interval don't check in step.
+func checkOffset(interval int) time.Duration {
+	return time.Duration(rand.Intn(interval)) * time.Second
+}
+
+// Alert transitions accepted in Target.NotifyOn
+var notifyEvents = map[string]bool{"down": true, "up": true, "cert": true, "changed": true, "degraded": true}
+
+var alertChannels = map[string]bool{"command": true, "email": true, "slack": true, "teams": true, "discord": true, "webhook": true, "pagerduty": true, "sms": true}
+
+// Whether the target has all the given tags.
+func (t *Target) hasTags(tags map[string]string) bool {
+	for k, v := range tags {
+		if t.Tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether this alert goes through the given notifier. Recoveries use
+// Target.RecoveryChannels when set, targets without AlertChannels the
+// first of routes matching their tags.
+func (s *TargetStatus) alertsVia(channel string, routes []AlertRoute) bool {
+	channels := s.Target.AlertChannels
+	if len(channels) == 0 {
+		for _, route := range routes {
+			if s.Target.hasTags(route.Tags) {
+				channels = route.Channels
+				break
+			}
+		}
+	}
+	if alertEvent(s) == "up" && len(s.Target.RecoveryChannels) > 0 {
+		channels = s.Target.RecoveryChannels
+	}
+	if len(channels) == 0 {
+		return true
+	}
+	for _, c := range channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the target wants alerts for the given transition.
+func (t *Target) notifies(event string) bool {
+	if len(t.NotifyOn) == 0 {
+		return event != "degraded"
+	}
+	for _, e := range t.NotifyOn {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// The transition an alert is about, see Target.NotifyOn.
+func alertEvent(status *TargetStatus) string {
+	switch {
+	case status.Online && status.CertWarning:
+		return "cert"
+	case status.Online && status.ContentChanged:
+		return "changed"
+	case status.Online && status.degraded:
+		return "degraded"
+	case status.Online:
+		return "up"
+	}
+	return "down"
+}
+
+// Send the alert for status through the target's notifiers. Reports
+// whether none of them failed.
+func alert(status *TargetStatus, config Config) (ok bool) {
+	ok = true
+	tlog := targetLog(status.Target)
+	if !pending.start(status.Target) {
+		tlog.Warn("alert NOT sent, shutting down", "event", "alert_skipped")
+		return true
+	}
+	defer pending.done(status.Target)
+
+	if status.Maintenance {
+		tlog.Info("alert NOT sent, in maintenance", "event", "alert_skipped")
+		return true
+	}
+	if isDisabled(status.Target.Id) {
+		tlog.Info("alert NOT sent, target disabled", "event", "alert_skipped")
+		return true
+	}
+	if !status.Online && !status.Target.canary && networkDown.Load() {
+		tlog.Info("alert NOT sent, monitor network down", "event", "alert_skipped")
+		return true
+	}
+
+	event := alertEvent(status)
+	if service := mutedBy(status.Target.Id); service != "" && (event == "down" || event == "up") {
+		tlog.Info("alert NOT sent, alerted through its service", "event", "alert_skipped", "service", service)
+		return true
+	}
+	if event == "down" {
+		if dep := status.Target.downDependency(); dep != 0 {
+			tlog.Info(fmt.Sprintf("alert NOT sent, suppressed by dependency %d", dep), "event", "alert_skipped", "dependency", dep)
+			status.Target.suppressedDown = true
+			return true
+		}
+		status.Target.suppressedDown = false
+	} else if event == "up" && status.Target.suppressedDown {
+		tlog.Info("alert NOT sent, down alert was suppressed by a dependency", "event", "alert_skipped")
+		status.Target.suppressedDown = false
+		return true
+	}
+	if !status.Target.notifies(event) {
+		tlog.Debug("alert NOT sent, not in NotifyOn", "event", "alert_skipped", "transition", event)
+		return true
+	}
+	if event == "up" && !config.Alert.notifyRecovery() && len(status.Target.RecoveryChannels) == 0 {
+		tlog.Debug("alert NOT sent, NotifyRecovery is off", "event", "alert_skipped", "transition", event)
+		return true
+	}
+	if quietHold(*status, config) {
+		tlog.Info("alert held until quiet hours end", "event", "alert_held", "transition", event)
+		return true
+	}
+
+	if !status.alertsVia("command", config.Alert.Routes) {
+		tlog.Debug("alert command NOT run, not in AlertChannels", "event", "alert_skipped", "channel", "command")
+	} else if status.Target.Commandrun != "" {
+		command, err := renderCommand(status.Target.Commandrun, *status)
+		if err == nil {
+			err = CommandRun(command, config)
+		}
+		if err != nil {
+			tlog.Error("alert command failed", "event", "alert_failed", "channel", "command", "error", err)
+			logAlert(status, "command", err)
+			ok = false
+		} else {
+			tlog.Info("alert command run", "event", "alert_sent", "channel", "command", "command", command)
+			logAlert(status, "command", nil)
+		}
+	} else {
+		tlog.Debug("alert command NOT run as no Commandrun specified", "event", "alert_skipped", "channel", "command")
+	}
+
+	// too many alerts at once, email, chat and webhooks get one digest
+	if digests.hold(*status, config) {
+		tlog.Info("alert held for the digest", "event", "alert_held")
+	} else {
+		if !status.alertsVia("email", config.Alert.Routes) {
+			tlog.Debug("alert email NOT sent, not in AlertChannels", "event", "alert_skipped", "channel", "email")
+		} else if to := emailRecipients(status.Target, config); len(to) > 0 {
+			err := EmailAlert(*status, config)
+			if err != nil {
+				tlog.Error("alert email failed", "event", "alert_failed", "channel", "email", "error", err)
+				logAlert(status, "email", err)
+				ok = false
+			} else {
+				tlog.Info("alert sent", "event", "alert_sent", "channel", "email", "to", strings.Join(to, ", "))
+				logAlert(status, "email", nil)
+			}
+		} else {
+			tlog.Debug("alert NOT sent as no 'To:' email specified", "event", "alert_skipped", "channel", "email")
+		}
+		if config.Alert.SlackWebhook != "" && status.alertsVia("slack", config.Alert.Routes) {
+			err := SlackAlert(*status, config)
+			if err != nil {
+				tlog.Error("Slack alert failed", "event", "alert_failed", "channel", "slack", "error", err)
+				logAlert(status, "slack", err)
+				ok = false
+			} else {
+				tlog.Info("alert sent", "event", "alert_sent", "channel", "slack")
+				logAlert(status, "slack", nil)
+			}
+		}
+		if config.Alert.TeamsWebhook != "" && status.alertsVia("teams", config.Alert.Routes) {
+			err := TeamsAlert(*status, config)
+			if err != nil {
+				tlog.Error("Teams alert failed", "event", "alert_failed", "channel", "teams", "error", err)
+				logAlert(status, "teams", err)
+				ok = false
+			} else {
+				tlog.Info("alert sent", "event", "alert_sent", "channel", "teams")
+				logAlert(status, "teams", nil)
+			}
+		}
+		if config.Alert.DiscordWebhook != "" && status.alertsVia("discord", config.Alert.Routes) {
+			err := DiscordAlert(*status, config)
+			if err != nil {
+				tlog.Error("Discord alert failed", "event", "alert_failed", "channel", "discord", "error", err)
+				logAlert(status, "discord", err)
+				ok = false
+			} else {
+				tlog.Info("alert sent", "event", "alert_sent", "channel", "discord")
+				logAlert(status, "discord", nil)
+			}
+		}
+		if config.Alert.WebhookURL != "" && status.alertsVia("webhook", config.Alert.Routes) {
+			err := WebhookAlert(*status, config)
+			if err != nil {
+				tlog.Error("webhook alert failed", "event", "alert_failed", "channel", "webhook", "error", err)
+				logAlert(status, "webhook", err)
+				ok = false
+			} else {
+				tlog.Info("alert sent", "event", "alert_sent", "channel", "webhook")
+				logAlert(status, "webhook", nil)
+			}
+		}
+	}
+	if len(config.Alert.Twilio.To) > 0 && status.alertsVia("sms", config.Alert.Routes) && (event == "down" || event == "up") {
+		err := SMSAlert(*status, config)
+		if err != nil {
+			tlog.Error("SMS alert failed", "event", "alert_failed", "channel", "sms", "error", err)
+			logAlert(status, "sms", err)
+			ok = false
+		} else {
+			tlog.Info("alert sent", "event", "alert_sent", "channel", "sms", "to", strings.Join(config.Alert.Twilio.To, ", "))
+			logAlert(status, "sms", nil)
+		}
+	}
+	// incidents follow up/down only, warnings while up don't page
+	if config.Alert.PagerDutyKey != "" && status.alertsVia("pagerduty", config.Alert.Routes) && (event == "down" || event == "up") {
+		err := PagerDutyAlert(*status, config)
+		if err != nil {
+			tlog.Error("PagerDuty alert failed", "event", "alert_failed", "channel", "pagerduty", "error", err)
+			logAlert(status, "pagerduty", err)
+			ok = false
+		} else {
+			tlog.Info("alert sent", "event", "alert_sent", "channel", "pagerduty")
+			logAlert(status, "pagerduty", nil)
+		}
+	}
+	countAlert(status.Target)
+	status.LastAlert = time.Now()
+	return ok
+}
+
+// Credentials are masked wherever a target is shown: status page, JSON API
+// and alert emails.
+func (t Target) MarshalJSON() ([]byte, error) {
+	type target Target
+	c := target(t)
+	if c.Password != "" {
+		c.Password = redacted
+	}
+	if u, err := url.Parse(c.Proxy); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			c.Proxy = u.String()
+		}
+	}
+	if len(c.Headers) > 0 {
+		c.Headers = make(map[string]string, len(t.Headers))
+		for k, v := range t.Headers {
+			if strings.EqualFold(k, "Authorization") {
+				v = redacted
+			}
+			c.Headers[k] = v
+		}
+	}
+	return json.Marshal(c)
+}
+
+// Keyword compiled as a regular expression, compiled only once.
+func (t *Target) keywordRegexp() (*regexp.Regexp, error) {
+	if t.keywordRe == nil && t.keywordErr == nil {
+		t.keywordRe, t.keywordErr = regexp.Compile(t.Keyword)
+	}
+	return t.keywordRe, t.keywordErr
+}
+
+// Address schemes probe knows about
+var schemes = map[string]bool{
+	"http": true, "https": true, "tcp": true, "udp": true,
+	"ping": true, "ping6": true, "dns": true, "srv": true, "ws": true, "wss": true,
+	"grpc": true, "grpcs": true, "file": true,
+	"exec": true,
+}
+
+// Network timeout for the target, falling back to the global one.
+func (t *Target) timeout(config Config) time.Duration {
+	if t.Timeout > 0 {
+		return time.Duration(t.Timeout) * time.Second
+	}
+	return time.Duration(config.Timeout) * time.Second
+}
+
+// Run a single check of the target, recording any error in status.
+func probe(t *Target, addrURL *url.URL, status *TargetStatus, config Config) (failed bool) {
+	var err error
+	tlog := targetLog(t)
+	// one deadline for the whole check, whatever the scheme
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout(config))
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	start := time.Now()
+	// ping reports its own round trip, without resolving and setup
+	var pingRTT time.Duration
+
+	switch addrURL.Scheme {
+	case "http", "https":
+		failed = checkHTTP(ctx, t, addrURL, status, config.maxBodyBytes(), config.CaptureFailureBody)
+	case "ping", "ping6":
+		network := "ip"
+		if addrURL.Scheme == "ping6" {
+			network = "ip6"
+		}
+		maxLoss := t.pingMaxLoss()
+		var stats PingStats
+		stats, err = Ping(ctx, t.resolver, addrURL.Hostname(), network, t.PingCount, t.PingSize)
+		status.PacketLoss = stats.Loss()
+		if err != nil {
+			tlog.Warn("ping error", "event", "check_failed", "error", err)
+			status.ErrorMsg = fmt.Sprintf("%s", err)
+			failed = true
+		} else if stats.Received == 0 || status.PacketLoss > maxLoss {
+			status.ErrorMsg = fmt.Sprintf("%.0f%% packet loss, %d of %d replies", status.PacketLoss, stats.Received, stats.Sent)
+			tlog.Warn("ping error", "event", "check_failed", "error", status.ErrorMsg)
+			failed = true
+		}
+		pingRTT = stats.RTT
+	case "ws", "wss":
+		err = checkWebSocket(ctx, t, addrURL, t.WebSocketPing)
+		if err != nil {
+			tlog.Warn("websocket error", "event", "check_failed", "error", err)
+			status.ErrorMsg = fmt.Sprintf("%s", err)
+			failed = true
+		}
+	case "grpc", "grpcs":
+		err = checkGRPC(ctx, t, addrURL, t.GRPCService)
+		if err != nil {
+			tlog.Warn("grpc error", "event", "check_failed", "error", err)
+			status.ErrorMsg = fmt.Sprintf("%s", err)
+			failed = true
+		}
+	case "exec":
+		err = checkCommand(ctx, t.CheckCommand)
+		if err != nil {
+			tlog.Warn("check command error", "event", "check_failed", "error", err)
+			status.ErrorMsg = fmt.Sprintf("%s", err)
+			failed = true
+		}
+	case "file":
+		err = checkFile(addrURL.Path, t.MaxAge, t.MinSize)
+		if err != nil {
+			tlog.Warn("file error", "event", "check_failed", "error", err)
+			status.ErrorMsg = fmt.Sprintf("%s", err)
+			failed = true
+		}
+	case "udp":
+		minBytes := t.MinBytes
+		if minBytes <= 0 {
+			minBytes = 1
+		}
+		_, err = UDPProbe(ctx, t.dialer(), addrURL.Host, []byte(t.Send), minBytes)
+		if err != nil {
+			tlog.Warn("udp error", "event", "check_failed", "error", err)
+			status.ErrorMsg = fmt.Sprintf("%s", err)
+			failed = true
+		}
+	case "dns":
+		var addrs []string
+		r := t.resolver
+		if t.Resolver != "" {
+			// ctx bounds the queries
+			r = newResolver(t.Resolver, 0)
+		}
+		addrs, err = LookupHost(ctx, r, addrURL.Hostname())
+		if err != nil {
+			tlog.Warn("dns error", "event", "check_failed", "error", err)
+			status.ErrorMsg = fmt.Sprintf("%s", err)
+			failed = true
+		} else if t.Keyword != "" && !containsAddr(addrs, t.Keyword) {
+			status.ErrorMsg = fmt.Sprintf("address '%s' not in %s", t.Keyword, strings.Join(addrs, ", "))
+			tlog.Warn("dns error", "event", "check_failed", "error", status.ErrorMsg)
+			failed = true
+		}
+	case "srv":
+		if t.srvAddrs == nil || !t.ResolveOnce {
+			t.srvAddrs, err = ResolveSRV(ctx, t.resolver, addrURL.Host)
+		}
+		if err != nil {
+			tlog.Warn("srv lookup error", "event", "check_failed", "error", err)
+			status.ErrorMsg = fmt.Sprintf("%s", err)
+			status.Endpoints = nil
+			failed = true
+		} else {
+			var ok bool
+			status.Endpoints, ok, status.ErrorMsg = CheckSRV(ctx, t.dialer(), t.srvAddrs, t.Quorum)
+			if !ok {
+				tlog.Warn("srv error", "event", "check_failed", "error", status.ErrorMsg)
+				failed = true
+			}
+		}
+	default:
+		var conn net.Conn
+		conn, err = t.dialer().DialContext(ctx, "tcp", addrURL.Host)
+		if err != nil {
+			tlog.Warn("tcp conn error", "event", "check_failed", "error", err)
+			status.ErrorMsg = fmt.Sprintf("%s", err)
+			status.FailureClass = classifyError(err)
+			failed = true
+		} else {
+			if t.Send != "" || t.Expect != "" {
+				err = sendExpect(conn, t.Send, t.Expect, deadline)
+				if err != nil {
+					tlog.Warn("tcp error", "event", "check_failed", "error", err)
+					status.ErrorMsg = fmt.Sprintf("%s", err)
+					status.FailureClass = classifyError(err)
+					failed = true
+				}
+			}
+			conn.Close()
+		}
+	}
+	status.Latency = time.Since(start)
+	if pingRTT > 0 {
+		status.Latency = pingRTT
+	}
+	if !failed && t.slow(status.Latency) {
+		// slow but still up
+		threshold := time.Duration(t.LatencyThreshold) * time.Millisecond
+		status.ErrorMsg = fmt.Sprintf("slow response, %s > %s", status.Latency, threshold)
+		tlog.Warn("latency warning", "event", "slow", "latency", status.Latency, "threshold", threshold)
+	}
+	return failed
+}
+
+// Whether a check took longer than Target.LatencyThreshold.
+func (t *Target) slow(latency time.Duration) bool {
+	return t.LatencyThreshold > 0 && latency > time.Duration(t.LatencyThreshold)*time.Millisecond
+}
+
+// Send the target's alert requests, as decided by its standoff. The time
+// of each alert sent goes to alerted, when not nil.
+func alertRoutine(ctx context.Context, alertRequest <-chan TargetStatus, alerted chan time.Time, config Config) {
+	s := standoff{delay: time.Duration(config.Standoff) * time.Second}
+	send := func(status *TargetStatus) {
+		last := status.LastAlert
+		alert(status, config)
+		if alerted != nil && status.LastAlert != last {
+			// the only sender, a time not picked up yet is replaced
+			select {
+			case <-alerted:
+			default:
+			}
+			alerted <- status.LastAlert
+		}
+	}
+	var timer *time.Timer
+	var held <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case req := <-alertRequest:
+			if due := s.request(&req, time.Now()); due != nil {
+				send(due)
+			}
+		case <-held:
+			if due := s.due(time.Now()); due != nil {
+				send(due)
+			}
+		}
+
+		// wake up when the held alert is due
+		if timer != nil {
+			timer.Stop()
+		}
+		timer, held = nil, nil
+		if due := s.deadline(); !due.IsZero() {
+			timer = time.NewTimer(time.Until(due))
+			held = timer.C
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package monitor
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+func TestJitterBounds(t *testing.T) {
+	d := 10 * time.Minute
+	for _, pct := range []int{0, 1, 10, 50, 100, 150, -5} {
+		lo := time.Duration(float64(d) * (1 - float64(pct)/100))
+		hi := time.Duration(float64(d) * (1 + float64(pct)/100))
+		if pct <= 0 {
+			lo, hi = d, d
+		}
+		spread := false
+		for i := 0; i < 1000; i++ {
+			r := jitter(d, pct)
+			if r < lo || r > hi || r < 0 {
+				t.Fatalf("jitter(%s, %d) = %s, outside [%s, %s]", d, pct, r, lo, hi)
+			}
+			spread = spread || r != d
+		}
+		if pct > 0 && !spread {
+			t.Errorf("jitter(%s, %d) never moved the interval", d, pct)
+		}
+	}
+	if r := jitter(0, 10); r != 0 {
+		t.Errorf("jitter(0, 10) = %s", r)
+	}
+}
+
+func TestCheckOffsetSpread(t *testing.T) {
+	// an unseeded math/rand repeats the sequence of seed 1 every run
+	unseeded := rand.New(rand.NewSource(1))
+	offsets := make(map[time.Duration]bool)
+	repeated := true
+	for i := 0; i < 20; i++ {
+		offset := checkOffset(60)
+		if offset < 0 || offset >= time.Minute {
+			t.Fatalf("offset %s outside the 60s interval", offset)
+		}
+		offsets[offset] = true
+		repeated = repeated && offset == time.Duration(unseeded.Intn(60))*time.Second
+	}
+	if len(offsets) < 2 {
+		t.Errorf("targets with equal intervals all got offset %v", offsets)
+	}
+	if repeated {
+		t.Error("offsets are the same every run, math/rand is not seeded")
+	}
+}
+
+// dueCheck of the running target with this name, if scheduled yet.
+func dueCheckOf(name string) (dueCheck, bool) {
+	dueChecks.Lock()
+	defer dueChecks.Unlock()
+	for _, c := range dueChecks.next {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return dueCheck{}, false
+}
+
+func TestScheduledTargetWaitsForCron(t *testing.T) {
+	captureLog(t)
+	sched, err := cron.ParseStandard("0 9 * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sched.Next(time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	res := make(chan TargetStatus, 1)
+	startTarget(ctx, &wg, Target{Id: 1, Name: "weekdays", Addr: "tcp://127.0.0.1:1", Schedule: "0 9 * * 1-5"}, res, Config{Timeout: 1})
+
+	deadline := time.Now().Add(time.Second)
+	c, ok := dueCheckOf("weekdays")
+	for !ok && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		c, ok = dueCheckOf("weekdays")
+	}
+	if !ok {
+		t.Fatal("scheduled target never waited for its next check")
+	}
+	if !c.at.Equal(want) {
+		t.Errorf("next check at %s, want the schedule's %s", c.at, want)
+	}
+	select {
+	case status := <-res:
+		t.Errorf("checked before the schedule fired: %+v", status)
+	default:
+	}
+
+	cancel()
+	wg.Wait()
+	if _, ok := dueCheckOf("weekdays"); ok {
+		t.Error("stopped target still scheduled")
+	}
+}
+
+func TestBadScheduleReportsConfigError(t *testing.T) {
+	captureLog(t)
+	var wg sync.WaitGroup
+	res := make(chan TargetStatus, 1)
+	startTarget(context.Background(), &wg, Target{Id: 1, Name: "bad", Addr: "tcp://127.0.0.1:1", Schedule: "every day"}, res, Config{Timeout: 1})
+	wg.Wait()
+	select {
+	case status := <-res:
+		if status.State != "error" || status.FailureClass != "config" {
+			t.Errorf("got state %s, class %s", status.State, status.FailureClass)
+		}
+	default:
+		t.Fatal("no status for a target with a bad schedule")
+	}
+}
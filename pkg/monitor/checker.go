@@ -0,0 +1,68 @@
+// Package monitor checks the availability of hosts and services and alerts
+// when they go down or come back up. The pingo2 command is a thin wrapper
+// around it, other programs can embed the checks through Checker.
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// Set at build time with
+// -ldflags "-X github.com/dzirg44/pingo2/pkg/monitor.Version=..."
+var Version = "dev"
+
+// Checker runs the checks of a Config. Config.MaxConcurrency, the
+// network check and the dependency states are shared process wide, so a
+// program runs one Checker at a time.
+type Checker struct {
+	Config Config
+}
+
+// Checker for a validated config, see Config.Validate.
+func NewChecker(config Config) *Checker {
+	config.Targets = append([]Target(nil), config.Targets...)
+	numberTargets(config.Targets)
+	limitConcurrency(config)
+	return &Checker{Config: config}
+}
+
+// Check a single target once, without retries or alerts.
+func (c *Checker) CheckOnce(ctx context.Context, t Target) (TargetStatus, error) {
+	config := c.Config
+	config.Once = true
+	res := make(chan TargetStatus, 1)
+	var wg sync.WaitGroup
+	startTarget(ctx, &wg, t, res, config)
+	wg.Wait()
+
+	select {
+	case status := <-res:
+		return status, nil
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return TargetStatus{}, err
+	}
+	// runTarget logged why
+	return TargetStatus{}, fmt.Errorf("target '%s' could not be checked, see the log", t.Name)
+}
+
+// Check every target of the config and alert on changes until ctx is
+// cancelled. Each check's status is sent on the returned channel, which
+// must be read, and is closed once all checks and alerts have stopped.
+func (c *Checker) Run(ctx context.Context) <-chan TargetStatus {
+	res := make(chan TargetStatus)
+	var wg sync.WaitGroup
+	if err := openEventLog(c.Config); err != nil {
+		slog.Error("event log could not be opened", "file", c.Config.EventLog, "error", err)
+	}
+	newRunner(ctx, &wg, res, c.Config)
+	go func() {
+		wg.Wait()
+		close(res)
+	}()
+	return res
+}
@@ -0,0 +1,66 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Expand template actions in an alert command, e.g.
+// `notify.sh {{quote .Target.Name}} {{.Online}}`, with the status as data.
+// Values are inserted as is; use quote for anything that may contain spaces
+// or shell characters, such as .ErrorMsg.
+func renderCommand(command string, status TargetStatus) (string, error) {
+	if !strings.Contains(command, "{{") {
+		return command, nil
+	}
+	tmpl, err := template.New("command").Funcs(template.FuncMap{"quote": shellQuote}).Parse(command)
+	if err != nil {
+		return "", fmt.Errorf("error parsing command '%s', err %s", command, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, status); err != nil {
+		return "", fmt.Errorf("error rendering command '%s', err %s", command, err)
+	}
+	return buf.String(), nil
+}
+
+// Single quote s for bash.
+func shellQuote(s interface{}) string {
+	return "'" + strings.Replace(fmt.Sprint(s), "'", `'\''`, -1) + "'"
+}
+
+func CommandRun(command string, config Config) error {
+	timeout := config.CommandTimeout
+	if timeout <= 0 {
+		timeout = config.Timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "/bin/bash", "-c", command)
+	cmd.Stderr = &stderr
+	// kill whatever the command forked too, not just bash
+	killProcessGroup(cmd)
+	err := cmd.Start()
+	if err != nil {
+		return fmt.Errorf("error run command '%s', err %s", command, err)
+	}
+	err = cmd.Wait()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("error run command '%s', timed out after %ds", command, timeout)
+	}
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("error run command '%s', exit code %d: %s", command, exitErr.ExitCode(), msg)
+		}
+		return fmt.Errorf("error run command '%s', err %s: %s", command, err, msg)
+	}
+	return nil
+}
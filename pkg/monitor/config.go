@@ -0,0 +1,582 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/robfig/cron/v3"
+	"golang.org/x/net/html/charset"
+	"gopkg.in/yaml.v3"
+
+	//"github.com/BurntSushi/toml"
+)
+
+type Config struct {
+	// Network timeout in seconds
+	Timeout int
+	// SMTP relay config
+	SMTP SMTPConfig
+	// Alert properties
+	Alert   Alert
+	Targets []Target
+	// standoff from sending alert if host down and back again
+	// within this many seconds
+	Standoff int
+	// SQLite database to store every check in, status is restored
+	// from it on startup
+	Database string
+	// File to append target transitions and alerts to as JSON lines, an
+	// audit trail apart from the log. Rotated once it reaches
+	// EventLogMaxSize megabytes, 10 by default
+	EventLog        string
+	EventLogMaxSize int
+	// Recurring maintenance windows for all targets, alerts are not
+	// sent while one is open
+	Maintenance []Window
+	// Services up while a quorum of their member targets is up
+	Services []ServiceGroup
+	// Suppress alerts for targets changing state too often
+	Flap FlapConfig
+	// Check targets that stay down less often
+	Breaker BreakerConfig
+	// Check every target a single time without alerting, then exit,
+	// see -once
+	Once bool
+	// Address checked every CheckInterval to tell whether the monitor
+	// itself is online, e.g. "ping://192.168.1.1". While it fails, target
+	// down alerts are replaced by a single network down alert
+	NetworkCheck string
+	// URL requested every HeartbeatInterval seconds (default 60) while
+	// pingo2 runs, for a dead man's switch such as healthchecks.io
+	Heartbeat         string
+	HeartbeatInterval int
+	// Daily window during which alerts of targets with RespectQuietHours
+	// are held, e.g. {"Start": "22:00", "End": "07:00", "Timezone": "Europe/Berlin"}
+	QuietHours *Window
+	// Check samples kept per target, defaults to 100
+	HistorySize int
+	// Error messages kept per target for the status API, defaults to 10
+	ErrorHistory int
+	// Keep up to this many bytes of the response body when an http(s)
+	// check fails, shown in the status API and alerts. Off when 0
+	CaptureFailureBody int
+	// Persist history to this file, so it survives restarts
+	HistoryFile string
+	// DNS server resolving target hostnames, "address:port", instead of
+	// the system resolver
+	Resolver string
+	// Proxy URL for http(s) checks, see Target.Proxy
+	Proxy string
+	// Warn when an https certificate expires within this many days
+	CertWarnDays int
+	// Bytes of an http(s) response body read for keyword and hash checks,
+	// defaults to 4 MiB. The rest is ignored
+	MaxBodyBytes int64
+	// Keep http(s) connections open between checks, saving the TCP and
+	// TLS handshakes. Off by default, fresh connections tell more about
+	// availability
+	ReuseConnections bool
+	// User-Agent for http(s) checks, defaults to "pingo2/<version>"
+	UserAgent string
+	// Maximum number of checks running at the same time, 0 is unlimited
+	MaxConcurrency int
+	// Seconds an alert command may run, defaults to Timeout
+	CommandTimeout int
+	// Randomly lengthen or shorten every check interval by up to this
+	// many percent, to keep checks spread out
+	Jitter int
+	// Consecutive failed checks before a target is considered down
+	Retries int
+	// Consecutive successful checks before a down target is considered up
+	RecoveryThreshold int
+	// Address of the status pages, e.g. "127.0.0.1:8888". Overrides -p
+	Listen string
+	// Listen address for Prometheus metrics e.g. ":9100", served on the
+	// status page port when empty
+	MetricsListen string
+	// StatsD collector to push metrics to, e.g. "127.0.0.1:8125". Tags
+	// use the DogStatsD format
+	Statsd string
+	// Path of the metrics endpoint, defaults to /metrics
+	MetricsPath string
+	// Windows in seconds over which uptime is computed, defaults to 1h, 24h and 30d
+	UptimeWindows []int
+	// Log level: debug, info, warn or error. Defaults to info
+	LogLevel string
+	// Log output format: text (default) or json
+	LogFormat string
+	// Seconds to wait for pending alerts on shutdown before exiting anyway
+	ShutdownTimeout int
+	// Prune history samples older than this many seconds, 0 disables
+	HistoryMaxAge int
+}
+
+type Alert struct {
+	// On alert, send to these email addresses, comma separated
+	ToEmail string
+	// On alert, send from this email address
+	FromEmail string
+	// Slack incoming webhook URL to post alerts to
+	SlackWebhook string
+	// Microsoft Teams incoming webhook URL to post alerts to as cards
+	TeamsWebhook string
+	// Discord webhook URL to post alerts to as embeds
+	DiscordWebhook string
+	// URL to post alerts to as JSON
+	WebhookURL string
+	// Extra headers for webhook requests, e.g. Authorization
+	WebhookHeaders map[string]string
+	// PagerDuty Events API v2 routing key. Down targets trigger an
+	// incident, which is resolved when they're back up
+	PagerDutyKey string
+	// Text messages through Twilio, for down and up alerts
+	Twilio TwilioConfig
+	// Notifiers for targets without AlertChannels, by tag. The first
+	// route whose Tags the target all has applies
+	Routes []AlertRoute
+	// Once every target was checked after startup, send one message
+	// listing those down, through email, chat and webhooks
+	StartupSummary bool
+	// Send a down alert, once, for targets that can't be checked as
+	// configured, e.g. with an unreadable address
+	NotifyConfigErrors bool
+	// Send alerts when targets come back up, defaults to true. See
+	// Target.RecoveryChannels for exceptions
+	NotifyRecovery *bool
+	// More than DigestThreshold alerts within DigestWindow seconds get
+	// batched: the rest of the window's alerts are sent as one email,
+	// chat message and webhook post. Commands, SMS and PagerDuty still
+	// run per target
+	DigestWindow    int
+	DigestThreshold int
+	// Trigger an alert every x seconds when in failed state
+	Interval int
+	// Seconds an acknowledged outage stays quiet before repeat alerts
+	// resume, defaults to a day
+	AckTTL int
+	// Double the repeat alert interval on each alert while a target stays
+	// down, up to this many seconds. Unset keeps repeating every Interval
+	MaxInterval int
+	// Randomize the repeat alert interval by +/- this many percent,
+	// defaults to 10, negative disables
+	Jitter int
+	// Email subject and plain text body templates (text/template syntax),
+	// see Target.SubjectTemplate. Empty keeps the default format
+	SubjectTemplate string
+	BodyTemplate    string
+	// HTML email body template (html/template syntax). When empty,
+	// alerts are sent as plain text only
+	HTMLBody string
+	// Link to the status page, made available to the HTML template
+	StatusURL string
+	// Retry policy for email and HTTP based alerts
+	Retry RetryConfig
+}
+
+type TwilioConfig struct {
+	AccountSID string
+	AuthToken  string
+	// Sending phone number, e.g. "+15005550006"
+	From string
+	To   []string
+}
+
+type AlertRoute struct {
+	// Tags the target must have, e.g. {"env": "dev"}
+	Tags map[string]string
+	// Notifiers used, as in Target.AlertChannels
+	Channels []string
+}
+
+type RetryConfig struct {
+	// Delivery attempts, including the first one
+	MaxAttempts int
+	// Seconds before the first retry, doubled after each attempt
+	BaseDelay int
+	// Upper bound for a single delay, in seconds
+	MaxDelay int
+	// Stop retrying once this many seconds have passed in total
+	MaxTotal int
+}
+
+type SMTPConfig struct {
+	Hostname string
+	Port     int
+	// Credentials for authenticated submission, sent with PLAIN auth
+	// once the connection is encrypted
+	User     string
+	Password string
+	// "none", "starttls" or "tls" for implicit TLS, e.g. on port 465.
+	// Empty uses implicit TLS on port 465 and STARTTLS when offered
+	Security string
+}
+
+var smtpSecurity = map[string]bool{"": true, "none": true, "starttls": true, "tls": true}
+
+// Read a config file in JSON or YAML format, by its extension, and number
+// its targets. A file that can't be decoded is an errInvalidConfig.
+func ReadConfig(filename string) (Config, error) {
+	config := Config{Timeout: 10}
+	file, err := os.Open(filename)
+	if err != nil {
+		return config, err
+	}
+	defer file.Close()
+
+	//_, err := toml.DecodeReader(file, &config)
+	if err := decodeConfig(file, &config, isYAML(filename)); err != nil {
+		return config, fmt.Errorf("%w, %s", errInvalidConfig, err)
+	}
+	numberTargets(config.Targets)
+	return config, nil
+}
+
+// Write a starter config checking a local HTTP server to filename, which
+// must not exist yet.
+func WriteDefaultConfig(filename string) error {
+	config := Config{
+		Timeout: 10,
+		Targets: []Target{Target{Name: "Local HTTP Server", Addr: "http://localhost"}},
+	}
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	//err := toml.NewEncoder(file).Encode(config)
+	if err := encodeConfig(file, config, isYAML(filename)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Give targets their id, by position in the config.
+func numberTargets(targets []Target) {
+	for i, _ := range targets {
+		targets[i].Id = i + 1
+	}
+}
+
+// Check the whole config, returning every problem found at once.
+func (config Config) Validate() error {
+	var problems []string
+	problem := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	if config.Timeout <= 0 {
+		problem("Timeout must be > 0, got %d", config.Timeout)
+	}
+	if config.Standoff < 0 {
+		problem("Standoff must be >= 0, got %d", config.Standoff)
+	}
+	if tw := config.Alert.Twilio; len(tw.To) > 0 && (tw.AccountSID == "" || tw.AuthToken == "" || tw.From == "") {
+		problem("Alert.Twilio needs AccountSID, AuthToken and From to send to %s", strings.Join(tw.To, ", "))
+	}
+	if _, err := texttemplate.New("subject").Parse(config.Alert.SubjectTemplate); err != nil {
+		problem("Alert.SubjectTemplate could not be read, %s", err)
+	}
+	if _, err := texttemplate.New("body").Parse(config.Alert.BodyTemplate); err != nil {
+		problem("Alert.BodyTemplate could not be read, %s", err)
+	}
+	if config.Alert.Interval < 0 {
+		problem("Alert.Interval must be >= 0, got %d", config.Alert.Interval)
+	}
+	emails := config.Alert.ToEmail != ""
+	for _, t := range config.Targets {
+		emails = emails || len(t.ToEmail) > 0
+	}
+	if emails && config.Alert.FromEmail == "" {
+		problem("Alert.FromEmail must be set along with ToEmail")
+	}
+	if config.SMTP.Hostname != "" && config.SMTP.Port <= 0 {
+		problem("SMTP.Port must be set along with SMTP.Hostname")
+	}
+	if !smtpSecurity[config.SMTP.Security] {
+		problem("SMTP.Security must be none, starttls or tls, got '%s'", config.SMTP.Security)
+	}
+	if config.SMTP.Password != "" && config.SMTP.User == "" {
+		problem("SMTP.User must be set along with SMTP.Password")
+	}
+	if config.SMTP.User != "" && config.SMTP.Hostname == "" {
+		problem("SMTP.Hostname must be set along with SMTP.User")
+	}
+	if config.SMTP.User != "" && config.SMTP.Security == "none" {
+		problem("SMTP credentials are only sent encrypted, set SMTP.Security to starttls or tls")
+	}
+
+	if config.NetworkCheck != "" {
+		if u, err := url.Parse(config.NetworkCheck); err != nil {
+			problem("NetworkCheck address could not be read, %s", err)
+		} else if !schemes[u.Scheme] {
+			problem("NetworkCheck: unsupported scheme '%s'", u.Scheme)
+		}
+	}
+	if config.Heartbeat != "" {
+		if u, err := url.Parse(config.Heartbeat); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			problem("Heartbeat must be an http(s) URL")
+		}
+	}
+	if config.Resolver != "" {
+		if _, _, err := net.SplitHostPort(config.Resolver); err != nil {
+			problem("Resolver must be \"address:port\", %s", err)
+		}
+	}
+	if config.Proxy != "" {
+		if _, err := url.Parse(config.Proxy); err != nil {
+			problem("Proxy address could not be read, %s", err)
+		}
+	}
+	for _, w := range config.UptimeWindows {
+		if w <= 0 {
+			problem("UptimeWindows must be > 0, got %d", w)
+		}
+	}
+	for _, w := range config.Maintenance {
+		if err := w.validate(); err != nil {
+			problem("maintenance window, %s", err)
+		}
+	}
+	if config.QuietHours != nil {
+		if err := config.QuietHours.validate(); err != nil {
+			problem("QuietHours, %s", err)
+		}
+	}
+	serviceNames := make(map[string]bool)
+	for _, s := range config.Services {
+		if s.Name == "" || serviceNames[s.Name] {
+			problem("service '%s': Name must be set and unique", s.Name)
+		}
+		serviceNames[s.Name] = true
+		if len(s.Targets) == 0 {
+			problem("service '%s': Targets must list member target ids", s.Name)
+		}
+		for _, id := range s.Targets {
+			if id < 1 || id > len(config.Targets) {
+				problem("service '%s': target %d is not a target", s.Name, id)
+			}
+		}
+		if s.Quorum < 0 || s.Quorum > len(s.Targets) {
+			problem("service '%s': Quorum must be between 0 and its %d targets, got %d", s.Name, len(s.Targets), s.Quorum)
+		}
+	}
+	for i, route := range config.Alert.Routes {
+		for _, c := range route.Channels {
+			if !alertChannels[c] {
+				problem("alert route %d: unknown channel '%s'", i+1, c)
+			}
+		}
+	}
+
+	for _, t := range config.Targets {
+		if t.Addr == "" {
+			// not checked
+			continue
+		}
+		name := fmt.Sprintf("target %d (%s)", t.Id, t.Name)
+		addrURL, err := url.Parse(t.Addr)
+		if err != nil {
+			problem("%s: address could not be read, %s", name, err)
+		} else if !schemes[addrURL.Scheme] {
+			problem("%s: unsupported scheme '%s'", name, addrURL.Scheme)
+		} else if addrURL.Scheme == "file" && (addrURL.Host != "" && addrURL.Host != "localhost" || addrURL.Path == "") {
+			problem("%s: file address must be an absolute path, e.g. file:///var/run/job.done", name)
+		} else if addrURL.Scheme == "exec" && t.CheckCommand == "" {
+			problem("%s: exec targets need a CheckCommand", name)
+		}
+		if t.Interval < 0 {
+			problem("%s: Interval must be >= 0, got %d", name, t.Interval)
+		}
+		if t.Timeout < 0 {
+			problem("%s: Timeout must be >= 0, got %d", name, t.Timeout)
+		}
+		if t.Proxy != "" {
+			if _, err := url.Parse(t.Proxy); err != nil {
+				problem("%s: proxy address could not be read, %s", name, err)
+			}
+		}
+		if _, err := texttemplate.New("subject").Parse(t.SubjectTemplate); err != nil {
+			problem("%s: SubjectTemplate could not be read, %s", name, err)
+		}
+		if _, err := texttemplate.New("body").Parse(t.BodyTemplate); err != nil {
+			problem("%s: BodyTemplate could not be read, %s", name, err)
+		}
+		if t.Schedule != "" {
+			if _, err := cron.ParseStandard(t.Schedule); err != nil {
+				problem("%s: schedule could not be read, %s", name, err)
+			}
+		}
+		if t.Retries < 0 {
+			problem("%s: Retries must be >= 0, got %d", name, t.Retries)
+		}
+		if t.RecoveryThreshold < 0 {
+			problem("%s: RecoveryThreshold must be >= 0, got %d", name, t.RecoveryThreshold)
+		}
+		for _, dep := range t.DependsOn {
+			if dep < 1 || dep > len(config.Targets) || dep == t.Id {
+				problem("%s: DependsOn %d is not another target", name, dep)
+			}
+		}
+		for _, w := range t.Maintenance {
+			if err := w.validate(); err != nil {
+				problem("%s: maintenance window, %s", name, err)
+			}
+		}
+		for _, e := range t.NotifyOn {
+			if !notifyEvents[e] {
+				problem("%s: unknown NotifyOn value '%s'", name, e)
+			}
+		}
+		if (t.ClientCert == "") != (t.ClientKey == "") {
+			problem("%s: ClientCert and ClientKey must be set together", name)
+		} else if t.ClientCert != "" {
+			if _, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey); err != nil {
+				problem("%s: client certificate could not be loaded, %s", name, err)
+			}
+		}
+		for _, c := range t.AlertChannels {
+			if !alertChannels[c] {
+				problem("%s: unknown AlertChannels value '%s'", name, c)
+			}
+		}
+		for _, c := range t.RecoveryChannels {
+			if !alertChannels[c] {
+				problem("%s: unknown RecoveryChannels value '%s'", name, c)
+			}
+		}
+		tagKeys := make([]string, 0, len(t.Tags))
+		for k := range t.Tags {
+			tagKeys = append(tagKeys, k)
+		}
+		sort.Strings(tagKeys)
+		labels := make(map[string]string, len(tagKeys))
+		for _, k := range tagKeys {
+			if k == "" {
+				problem("%s: empty Tags key", name)
+			} else if other, ok := labels[tagLabel(k)]; ok {
+				problem("%s: Tags '%s' and '%s' are both the metrics label %s", name, other, k, tagLabel(k))
+			} else {
+				labels[tagLabel(k)] = k
+			}
+		}
+		if t.Charset != "" {
+			if enc, _ := charset.Lookup(t.Charset); enc == nil {
+				problem("%s: unknown Charset '%s'", name, t.Charset)
+			}
+		}
+		if t.KeywordMode != "" && t.KeywordMode != "all" && t.KeywordMode != "any" {
+			problem("%s: KeywordMode must be 'all' or 'any', got '%s'", name, t.KeywordMode)
+		}
+		if t.KeywordRegex {
+			if _, err := regexp.Compile(t.Keyword); err != nil {
+				problem("%s: invalid keyword regex, %s", name, err)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// YAML is picked by the .yaml or .yml extension, JSON otherwise.
+func isYAML(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// YAML goes through encoding/json, so that both formats share the same keys
+// and case-insensitive field matching. ${VAR} references are expanded
+// from the environment, see expandEnv.
+func decodeConfig(r io.Reader, config *Config, isYAML bool) error {
+	if !isYAML {
+		if err := json.NewDecoder(r).Decode(config); err != nil {
+			return err
+		}
+		return expandConfig(config)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	j, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(j, config); err != nil {
+		return err
+	}
+	return expandConfig(config)
+}
+
+// Expand ${VAR} references and address lists once the config is read.
+func expandConfig(config *Config) error {
+	if err := expandEnv(config); err != nil {
+		return err
+	}
+	targets, ids, err := expandTargets(config.Targets)
+	if err != nil {
+		return err
+	}
+	config.Targets = targets
+	if ids == nil {
+		return nil
+	}
+	// a member with an address list or range brings all its hosts
+	for i, s := range config.Services {
+		var members []int
+		for _, id := range s.Targets {
+			if len(ids[id]) == 0 {
+				return fmt.Errorf("service '%s': target %d is not a target", s.Name, id)
+			}
+			members = append(members, ids[id]...)
+		}
+		config.Services[i].Targets = members
+	}
+	return nil
+}
+
+func encodeConfig(w io.Writer, config Config, isYAML bool) error {
+	if !isYAML {
+		return json.NewEncoder(w).Encode(config)
+	}
+	j, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(j, &doc); err != nil {
+		return err
+	}
+	return yaml.NewEncoder(w).Encode(doc)
+}
+
+// Response bytes read by http(s) checks.
+func (config Config) maxBodyBytes() int64 {
+	if config.MaxBodyBytes > 0 {
+		return config.MaxBodyBytes
+	}
+	return MaxBodyBytes
+}
+
+// Whether recoveries are alerted by default.
+func (alert Alert) notifyRecovery() bool {
+	return alert.NotifyRecovery == nil || *alert.NotifyRecovery
+}
@@ -0,0 +1,142 @@
+package monitor
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const jsonConfig = `{
+	"Timeout": 5,
+	"Standoff": 60,
+	"SMTP": {"Hostname": "mail.example.com", "Port": 587, "User": "pingo2", "Password": "secret", "Security": "starttls"},
+	"Alert": {"FromEmail": "pingo2@example.com", "ToEmail": "ops@example.com", "Interval": 600},
+	"QuietHours": {"Days": ["Sat", "Sun"], "Start": "22:00", "End": "07:00"},
+	"UptimeWindows": [3600, 86400],
+	"Targets": [
+		{
+			"Name": "web",
+			"Addr": "https://example.com/health",
+			"Interval": 60,
+			"ExpectStatus": [200, 204],
+			"FollowRedirects": false,
+			"Headers": {"Accept": "application/json"},
+			"Tags": {"env": "prod"},
+			"Keywords": ["ok", "ready"]
+		},
+		{"Name": "db", "Addr": "tcp://db:5432", "DependsOn": [1], "Schedule": "0 9 * * 1-5"}
+	]
+}`
+
+const yamlConfig = `
+timeout: 5
+standoff: 60
+smtp:
+  hostname: mail.example.com
+  port: 587
+  user: pingo2
+  password: secret
+  security: starttls
+alert:
+  fromemail: pingo2@example.com
+  toemail: ops@example.com
+  interval: 600
+quiethours:
+  days: [Sat, Sun]
+  start: "22:00"
+  end: "07:00"
+uptimewindows: [3600, 86400]
+targets:
+  - name: web
+    addr: https://example.com/health
+    interval: 60
+    expectstatus: [200, 204]
+    followredirects: false
+    headers:
+      Accept: application/json
+    tags:
+      env: prod
+    keywords: [ok, ready]
+  - name: db
+    addr: tcp://db:5432
+    dependson: [1]
+    schedule: "0 9 * * 1-5"
+`
+
+func decodeTestConfig(t *testing.T, doc string, yaml bool) Config {
+	var config Config
+	if err := decodeConfig(strings.NewReader(doc), &config, yaml); err != nil {
+		t.Fatal(err)
+	}
+	numberTargets(config.Targets)
+	return config
+}
+
+func TestYAMLConfigMatchesJSON(t *testing.T) {
+	fromJSON := decodeTestConfig(t, jsonConfig, false)
+	fromYAML := decodeTestConfig(t, yamlConfig, true)
+	if !reflect.DeepEqual(fromJSON, fromYAML) {
+		t.Fatalf("configs differ\njson: %+v\nyaml: %+v", fromJSON, fromYAML)
+	}
+	if fromYAML.Targets[0].FollowRedirects == nil || *fromYAML.Targets[0].FollowRedirects {
+		t.Error("FollowRedirects: false lost")
+	}
+	if err := fromYAML.Validate(); err != nil {
+		t.Errorf("valid config rejected: %s", err)
+	}
+}
+
+func TestConfigRoundTrip(t *testing.T) {
+	want := decodeTestConfig(t, jsonConfig, false)
+	for _, yaml := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := encodeConfig(&buf, want, yaml); err != nil {
+			t.Fatal(err)
+		}
+		if got := decodeTestConfig(t, buf.String(), yaml); !reflect.DeepEqual(got, want) {
+			t.Errorf("yaml %v: round trip differs\ngot:  %+v\nwant: %+v", yaml, got, want)
+		}
+	}
+}
+
+func TestIsYAML(t *testing.T) {
+	for name, want := range map[string]bool{"pingo2.yaml": true, "pingo2.YML": true, "pingo2.json": false, "pingo2": false} {
+		if got := isYAML(name); got != want {
+			t.Errorf("isYAML(%s) = %v", name, got)
+		}
+	}
+}
+
+func TestReadConfigMissingFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "pingo2.yaml")
+	if _, err := ReadConfig(filename); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("got %v for a missing file", err)
+	}
+	if _, err := os.Stat(filename); err == nil {
+		t.Fatal("ReadConfig created the file")
+	}
+
+	if err := WriteDefaultConfig(filename); err != nil {
+		t.Fatal(err)
+	}
+	config, err := ReadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Targets) != 1 || config.Targets[0].Id != 1 || config.Targets[0].Addr != "http://localhost" {
+		t.Errorf("default config read back as %+v", config.Targets)
+	}
+	if err := WriteDefaultConfig(filename); err == nil {
+		t.Error("existing config overwritten")
+	}
+
+	os.WriteFile(filename, []byte("targets: ["), 0644)
+	if _, err := ReadConfig(filename); !errors.Is(err, errInvalidConfig) {
+		t.Errorf("got %v for a broken file", err)
+	}
+}
@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Plain status table served at "/", for browsers without JavaScript.
+var dashboardTpl = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"since": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return time.Since(t).Round(time.Second).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta http-equiv="refresh" content="{{.Refresh}}">
+	<title>Pingo2</title>
+	<style>
+		body{padding: 40px; color: #33333A; font-family: Arial }
+		table{ border-collapse: collapse}
+		td, th { font-weight: normal; padding: 6px; text-align: left}
+		th{ background-color: #90909D; color: #FFF; border-bottom: 1px solid #445}
+		td{ border-bottom: 1px solid #999;}
+		.online{ background-color: #3E3; color: #FFF; padding: 3px 5px; border-radius: 5px}
+		.offline{ background-color: #E33; color: #FFF; padding: 3px 5px; border-radius: 5px}
+		.warn{ background-color: #E93; color: #FFF; padding: 3px 5px; border-radius: 5px}
+	</style>
+</head>
+<body>
+	<h1>Pingo2</h1>
+	<p>{{len .Statuses}} targets, refreshed every {{.Refresh}}s. <a href="/status">Details</a></p>
+	<table>
+		<tr><th>Name</th><th>Address</th><th>State</th><th>For</th><th>Last check</th><th>Error</th></tr>
+		{{range .Statuses}}
+		<tr>
+			<td>{{.Target.Name}}</td>
+			<td>{{.Target.Addr}}</td>
+			<td>{{if eq .State "warn"}}<span class="warn">WARN</span>{{else if eq .State "error"}}<span class="offline">ERROR</span>{{else if .Online}}<span class="online">UP</span>{{else}}<span class="offline">DOWN</span>{{end}}{{if .Disabled}} disabled{{end}}</td>
+			<td>{{since .Since}}</td>
+			<td>{{since .LastCheck}} ago</td>
+			<td>{{.ErrorMsg}}</td>
+		</tr>
+		{{end}}
+	</table>
+</body>
+</html>
+`))
+
+func dashboardHandler(state *State) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// "/" is also the fallback for unknown paths
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		data := struct {
+			Statuses []TargetStatus
+			Refresh  int
+		}{state.Snapshot(), CheckInterval}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := dashboardTpl.Execute(w, data); err != nil {
+			slog.Error("HTTP error writing dashboard", "error", err)
+		}
+	}
+}
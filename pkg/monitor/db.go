@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"database/sql"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Status history in SQLite, see Config.Database.
+type DB struct {
+	*sql.DB
+}
+
+const dbSchema = `
+CREATE TABLE IF NOT EXISTS status (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	target_id  INTEGER NOT NULL,
+	online     BOOLEAN NOT NULL,
+	error_msg  TEXT NOT NULL,
+	latency_ns INTEGER NOT NULL,
+	checked_at INTEGER NOT NULL,
+	since      INTEGER NOT NULL,
+	last_alert INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS status_target ON status (target_id, id);
+`
+
+func openDB(filename string) (*DB, error) {
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(dbSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &DB{db}, nil
+}
+
+// Store the result of a check.
+func (db *DB) record(s TargetStatus) error {
+	_, err := db.Exec(`INSERT INTO status (target_id, online, error_msg, latency_ns, checked_at, since, last_alert)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		s.Target.Id, s.Online, s.ErrorMsg, int64(s.Latency), unixTime(s.LastCheck), unixTime(s.Since), unixTime(s.LastAlert))
+	return err
+}
+
+// Last stored status of every target, keyed by target id. Only the fields
+// needed to carry on after a restart are filled in.
+func (db *DB) last() (map[int]TargetStatus, error) {
+	rows, err := db.Query(`SELECT target_id, online, error_msg, since, last_alert FROM status
+		WHERE id IN (SELECT MAX(id) FROM status GROUP BY target_id)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	last := make(map[int]TargetStatus)
+	for rows.Next() {
+		var id int
+		var since, lastAlert int64
+		var s TargetStatus
+		if err := rows.Scan(&id, &s.Online, &s.ErrorMsg, &since, &lastAlert); err != nil {
+			return nil, err
+		}
+		s.Since = fromUnixTime(since)
+		s.LastAlert = fromUnixTime(lastAlert)
+		last[id] = s
+	}
+	return last, rows.Err()
+}
+
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fromUnixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
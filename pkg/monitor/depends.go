@@ -0,0 +1,32 @@
+package monitor
+
+import "sync"
+
+// Targets currently down, by id, so their dependents can tell. See
+// Target.DependsOn.
+var downTargets = struct {
+	sync.Mutex
+	ids map[int]bool
+}{ids: make(map[int]bool)}
+
+func setDown(id int, down bool) {
+	downTargets.Lock()
+	defer downTargets.Unlock()
+	if down {
+		downTargets.ids[id] = true
+	} else {
+		delete(downTargets.ids, id)
+	}
+}
+
+// The first of the target's dependencies that is down, 0 if none.
+func (t *Target) downDependency() int {
+	downTargets.Lock()
+	defer downTargets.Unlock()
+	for _, id := range t.DependsOn {
+		if downTargets.ids[id] {
+			return id
+		}
+	}
+	return 0
+}
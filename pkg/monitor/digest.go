@@ -0,0 +1,206 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gomail/gomail"
+)
+
+// Alerts batched into a single message while too many fire at once, see
+// Alert.DigestThreshold.
+type digest struct {
+	sync.Mutex
+	// times of recent alerts, within Alert.DigestWindow
+	recent []time.Time
+	// alerts waiting for the next digest
+	held   []TargetStatus
+	timer  *time.Timer
+	config Config
+}
+
+var digests digest
+
+// Whether the alert is held for a digest rather than sent right away.
+// Once more than DigestThreshold alerts fired within DigestWindow, the
+// others are collected and sent together at the end of the window.
+func (d *digest) hold(status TargetStatus, config Config) bool {
+	window := time.Duration(config.Alert.DigestWindow) * time.Second
+	if config.Alert.DigestThreshold <= 0 || window <= 0 {
+		return false
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	now := time.Now()
+	i := 0
+	for i < len(d.recent) && now.Sub(d.recent[i]) > window {
+		i++
+	}
+	d.recent = append(d.recent[i:], now)
+	if len(d.recent) <= config.Alert.DigestThreshold {
+		return false
+	}
+
+	d.held = append(d.held, status)
+	d.config = config
+	if d.timer == nil {
+		d.timer = time.AfterFunc(window, d.flush)
+	}
+	return true
+}
+
+// Send the held alerts, if any.
+func (d *digest) flush() {
+	d.Lock()
+	held, config := d.held, d.config
+	d.held = nil
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.Unlock()
+	if len(held) > 0 {
+		sendDigest("digest", held, config)
+	}
+}
+
+// Held alerts whose target uses the given notifier.
+func heldVia(held []TargetStatus, channel string, routes []AlertRoute) []TargetStatus {
+	var via []TargetStatus
+	for _, status := range held {
+		if status.alertsVia(channel, routes) {
+			via = append(via, status)
+		}
+	}
+	return via
+}
+
+// One line per alert, e.g. "DOWN web (http://example.com): timeout".
+func digestText(held []TargetStatus) string {
+	var lines []string
+	for _, status := range held {
+		status := status
+		line := fmt.Sprintf("%s %s (%s)", strings.ToUpper(alertEvent(&status)), status.Target.Name, status.Target.Addr)
+		if status.ErrorMsg != "" {
+			line += ": " + status.ErrorMsg
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func digestSubject(kind string, held []TargetStatus) string {
+	down := 0
+	for _, status := range held {
+		if !status.Online {
+			down++
+		}
+	}
+	subject := fmt.Sprintf("Pingo2 %s: %d DOWN", kind, down)
+	if other := len(held) - down; other > 0 {
+		subject += fmt.Sprintf(", %d other alerts", other)
+	}
+	return subject
+}
+
+// Send held alerts as one message through email, chat and webhooks.
+// kind names the message, e.g. "digest".
+func sendDigest(kind string, held []TargetStatus, config Config) {
+	slog.Warn("sending alert "+kind, "event", "digest", "alerts", len(held))
+
+	if via := heldVia(held, "email", config.Alert.Routes); len(via) > 0 {
+		// everybody who would have gotten one of the alerts
+		var to []string
+		seen := make(map[string]bool)
+		for _, status := range via {
+			for _, addr := range emailRecipients(status.Target, config) {
+				if !seen[addr] {
+					seen[addr] = true
+					to = append(to, addr)
+				}
+			}
+		}
+		if len(to) > 0 {
+			msg := gomail.NewMessage()
+			msg.SetHeader("From", config.Alert.FromEmail)
+			msg.SetHeader("To", to...)
+			msg.SetHeader("Subject", digestSubject(kind, via))
+			msg.SetBody("text/plain", fmt.Sprintf("%s\n\n%s\n", time.Now(), digestText(via)))
+			if err := sendEmail(msg, config); err != nil {
+				slog.Error("digest email failed", "event", "alert_failed", "channel", "email", "error", err)
+			} else {
+				slog.Info("digest sent", "event", "alert_sent", "channel", "email", "to", strings.Join(to, ", "))
+			}
+		}
+	}
+
+	if via := heldVia(held, "slack", config.Alert.Routes); config.Alert.SlackWebhook != "" && len(via) > 0 {
+		text := fmt.Sprintf(":rotating_light: *%s*\n%s", digestSubject(kind, via), digestText(via))
+		body, err := json.Marshal(map[string]string{"text": text})
+		if err == nil {
+			err = postAlert("Slack", config.Alert.SlackWebhook, "application/json", nil, body, config)
+		}
+		if err != nil {
+			slog.Error("Slack digest failed", "event", "alert_failed", "channel", "slack", "error", err)
+		} else {
+			slog.Info("digest sent", "event", "alert_sent", "channel", "slack")
+		}
+	}
+
+	if via := heldVia(held, "teams", config.Alert.Routes); config.Alert.TeamsWebhook != "" && len(via) > 0 {
+		subject := digestSubject(kind, via)
+		err := postTeams(teamsCard{Summary: subject, Title: subject, ThemeColor: "EE3333", Text: "<pre>" + html.EscapeString(digestText(via)) + "</pre>"}, config)
+		if err != nil {
+			slog.Error("Teams digest failed", "event", "alert_failed", "channel", "teams", "error", err)
+		} else {
+			slog.Info("digest sent", "event", "alert_sent", "channel", "teams")
+		}
+	}
+
+	if via := heldVia(held, "discord", config.Alert.Routes); config.Alert.DiscordWebhook != "" && len(via) > 0 {
+		err := postDiscord(discordEmbed{Title: digestSubject(kind, via), Description: digestText(via), Color: 0xEE3333}, config)
+		if err != nil {
+			slog.Error("Discord digest failed", "event", "alert_failed", "channel", "discord", "error", err)
+		} else {
+			slog.Info("digest sent", "event", "alert_sent", "channel", "discord")
+		}
+	}
+
+	if via := heldVia(held, "webhook", config.Alert.Routes); config.Alert.WebhookURL != "" && len(via) > 0 {
+		payloads := make([]webhookPayload, len(via))
+		for i, status := range via {
+			payloads[i] = newWebhookPayload(status)
+		}
+		body, err := json.Marshal(payloads)
+		if err == nil {
+			err = postAlert("webhook", config.Alert.WebhookURL, "application/json", config.Alert.WebhookHeaders, body, config)
+		}
+		if err != nil {
+			slog.Error("webhook digest failed", "event", "alert_failed", "channel", "webhook", "error", err)
+		} else {
+			slog.Info("digest sent", "event", "alert_sent", "channel", "webhook")
+		}
+	}
+}
+
+// Send one message listing the targets down after the first round of
+// checks, see Alert.StartupSummary.
+func startupSummary(state *State, config Config) {
+	var down []TargetStatus
+	for _, status := range state.Snapshot() {
+		if !status.Online && !status.Maintenance {
+			down = append(down, status)
+		}
+	}
+	if len(down) == 0 {
+		slog.Info("startup summary NOT sent, all targets up", "event", "alert_skipped")
+		return
+	}
+	sendDigest("startup summary", down, config)
+}
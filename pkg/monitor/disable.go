@@ -0,0 +1,91 @@
+package monitor
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Targets disabled through the HTTP API, by id. Their checks and alerts
+// are skipped until enabled again.
+var disabledTargets = struct {
+	sync.Mutex
+	ids map[int]bool
+}{ids: make(map[int]bool)}
+
+func setDisabled(id int, disabled bool) {
+	disabledTargets.Lock()
+	defer disabledTargets.Unlock()
+	if disabled {
+		disabledTargets.ids[id] = true
+	} else {
+		delete(disabledTargets.ids, id)
+	}
+}
+
+func isDisabled(id int) bool {
+	disabledTargets.Lock()
+	defer disabledTargets.Unlock()
+	return disabledTargets.ids[id]
+}
+
+// POST /targets/{id}/disable, /targets/{id}/enable and /targets/{id}/ack,
+// answering with the target's status. An ack is made by the "by" form
+// value, or the client address.
+func targetsHandler(state *State) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/targets/"), "/")
+		if len(parts) != 2 || (parts[1] != "disable" && parts[1] != "enable" && parts[1] != "ack") {
+			http.NotFound(w, r)
+			return
+		}
+		id, err := strconv.Atoi(parts[0])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var change func(*TargetStatus)
+		if parts[1] == "ack" {
+			ack := &Ack{By: r.FormValue("by"), Time: time.Now()}
+			if ack.By == "" {
+				ack.By = r.RemoteAddr
+			}
+			change = func(status *TargetStatus) {
+				if !status.Online {
+					status.Ack = ack
+				}
+			}
+		} else {
+			disabled := parts[1] == "disable"
+			change = func(status *TargetStatus) { status.Disabled = disabled }
+		}
+		status, ok := state.modify(id, change)
+		if !ok {
+			// not checked yet or no such target
+			http.NotFound(w, r)
+			return
+		}
+
+		tlog := targetLog(status.Target)
+		if parts[1] == "ack" {
+			if status.Online {
+				http.Error(w, "target is up, nothing to acknowledge", http.StatusConflict)
+				return
+			}
+			setAck(id, status.Ack)
+			tlog.Info("outage acknowledged through the HTTP API", "event", "ack", "by", status.Ack.By, "remote", r.RemoteAddr)
+		} else {
+			setDisabled(id, status.Disabled)
+			tlog.Info("target "+parts[1]+"d through the HTTP API", "event", parts[1], "remote", r.RemoteAddr)
+		}
+		writeJSON(w, status)
+	}
+}
@@ -0,0 +1,61 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Resolve host through r, the system resolver when nil. Fails when no
+// address comes back.
+func LookupHost(ctx context.Context, r *net.Resolver, host string) ([]string, error) {
+	addrs, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses for %s", host)
+	}
+	return addrs, nil
+}
+
+// Resolver sending every query to addr, "address:port".
+func newResolver(addr string, timeout time.Duration) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{Timeout: timeout}
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+// Resolver for target hostnames, Config.Resolver when set. Nil stands for
+// the system resolver, as with net.Dialer.
+func configResolver(config Config) *net.Resolver {
+	if config.Resolver == "" {
+		return nil
+	}
+	return newResolver(config.Resolver, time.Duration(config.Timeout)*time.Second)
+}
+
+// Dialer resolving through the target's resolver, see Config.Resolver.
+func (t *Target) dialer() *net.Dialer {
+	return &net.Dialer{Resolver: t.resolver}
+}
+
+// Whether want is among the looked up addrs, comparing IPs in their
+// canonical form so "::1" matches "0:0:0:0:0:0:0:1".
+func containsAddr(addrs []string, want string) bool {
+	wantIP := net.ParseIP(want)
+	for _, a := range addrs {
+		if a == want {
+			return true
+		}
+		if ip := net.ParseIP(a); ip != nil && wantIP != nil && ip.Equal(wantIP) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package monitor
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNS server on a local UDP port answering A queries for any name with ip.
+func stubResolver(t *testing.T, ip net.IP) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) == 0 {
+				continue
+			}
+			q := query.Questions[0]
+			reply := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+				Questions: query.Questions,
+			}
+			if q.Type == dnsmessage.TypeA {
+				var a dnsmessage.AResource
+				copy(a.A[:], ip.To4())
+				reply.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 60},
+					Body:   &a,
+				}}
+			}
+			packed, err := reply.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(packed, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestConfigResolver(t *testing.T) {
+	if configResolver(Config{}) != nil {
+		t.Fatal("no Config.Resolver should mean the system resolver")
+	}
+	system := net.DefaultResolver
+	r := configResolver(Config{Resolver: stubResolver(t, net.IPv4(192, 0, 2, 7)), Timeout: 2})
+	if net.DefaultResolver != system {
+		t.Fatal("net.DefaultResolver replaced")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	addrs, err := LookupHost(ctx, r, "stub.pingo2.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsAddr(addrs, "192.0.2.7") {
+		t.Fatalf("got %v, want the stub's 192.0.2.7", addrs)
+	}
+}
+
+// A tcp target's hostname is resolved by Config.Resolver, not the system.
+func TestProbeThroughConfigResolver(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	config := Config{Resolver: stubResolver(t, net.IPv4(127, 0, 0, 1)), Timeout: 2}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	addrURL, _ := url.Parse("tcp://stub.pingo2.test:" + port)
+	target := &Target{Name: "stub", resolver: configResolver(config)}
+	status := TargetStatus{Target: target}
+	if failed := probe(target, addrURL, &status, config); failed {
+		t.Fatalf("check through the stub resolver failed: %s", status.ErrorMsg)
+	}
+}
@@ -0,0 +1,142 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/textproto"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/go-gomail/gomail"
+)
+
+// data handed to the HTML email template
+type emailData struct {
+	TargetStatus
+	Time      time.Time
+	StatusURL string
+}
+
+// Alert.ToEmail, a comma separated list, followed by the target's own
+// recipients.
+func emailRecipients(t *Target, config Config) []string {
+	var to []string
+	for _, addr := range strings.Split(config.Alert.ToEmail, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return append(to, t.ToEmail...)
+}
+
+func EmailAlert(status TargetStatus, config Config) error {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", config.Alert.FromEmail)
+	msg.SetHeader("To", emailRecipients(status.Target, config)...)
+	subject := "Host "
+	if status.Online && status.CertWarning {
+		subject = "Certificate EXPIRING: "
+	} else if status.Online && status.ContentChanged {
+		subject = "Content CHANGED: "
+	} else if status.Online && status.degraded {
+		subject += "DEGRADED: "
+	} else if status.Online {
+		subject += "UP: "
+	} else {
+		subject += "DOWN: "
+	}
+	subject += status.Target.Name
+
+	statusJson, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	body := fmt.Sprintf("%s\n\n%s\n", now, statusJson)
+
+	// templates of the target win over the global ones
+	data := emailData{status, now, config.Alert.StatusURL}
+	subjectTmpl, bodyTmpl := config.Alert.SubjectTemplate, config.Alert.BodyTemplate
+	if status.Target.SubjectTemplate != "" {
+		subjectTmpl = status.Target.SubjectTemplate
+	}
+	if status.Target.BodyTemplate != "" {
+		bodyTmpl = status.Target.BodyTemplate
+	}
+	if subjectTmpl != "" {
+		subject, err = emailText(subjectTmpl, data)
+		if err != nil {
+			return fmt.Errorf("error rendering alert subject, err %s", err)
+		}
+		// a header is a single line
+		subject = strings.Join(strings.Fields(subject), " ")
+	}
+	if bodyTmpl != "" {
+		body, err = emailText(bodyTmpl, data)
+		if err != nil {
+			return fmt.Errorf("error rendering alert body, err %s", err)
+		}
+	}
+
+	msg.SetHeader("Subject", subject)
+	msg.SetBody("text/plain", body)
+
+	// Send multipart/alternative with an HTML part if a template is set
+	if config.Alert.HTMLBody != "" {
+		html, err := emailHTML(config.Alert.HTMLBody, data)
+		if err != nil {
+			return fmt.Errorf("error rendering HTML alert body, err %s", err)
+		}
+		msg.AddAlternative("text/html", html)
+	}
+
+	return sendEmail(msg, config)
+}
+
+// Send msg through the configured SMTP server.
+func sendEmail(msg *gomail.Message, config Config) error {
+	hostname := config.SMTP.Hostname
+	port := config.SMTP.Port
+	if config.SMTP.Hostname == "" {
+		hostname = "localhost"
+		port = 25
+	}
+
+	return retryAlert("email", config, func() (time.Duration, bool, error) {
+		err := sendSMTP(hostname, port, config.SMTP, msg)
+		var smtpErr *textproto.Error
+		if errors.As(err, &smtpErr) && smtpErr.Code >= 500 {
+			// rejected for good, e.g. an unknown recipient
+			return 0, false, err
+		}
+		return 0, true, err
+	})
+}
+
+func emailText(tmpl string, data emailData) (string, error) {
+	t, err := texttemplate.New("email").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func emailHTML(tmpl string, data emailData) (string, error) {
+	t, err := template.New("email").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
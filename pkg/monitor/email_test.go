@@ -0,0 +1,281 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// SMTP server on a local port keeping the messages it was sent.
+type fakeSMTP struct {
+	port int
+	// offers STARTTLS and AUTH PLAIN when set
+	tls  *tls.Config
+	mu   sync.Mutex
+	msgs []string
+	// whether each message came encrypted, and the credentials sent
+	encrypted []bool
+	auth      string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &fakeSMTP{port: ln.Addr().(*net.TCPAddr).Port}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	c := textproto.NewConn(conn)
+	c.PrintfLine("220 fake ESMTP")
+	encrypted := false
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line + " ")
+		switch strings.ToUpper(fields[0]) {
+		case "EHLO", "HELO":
+			if s.tls == nil {
+				c.PrintfLine("250 fake")
+			} else if !encrypted {
+				c.PrintfLine("250-fake\r\n250 STARTTLS")
+			} else {
+				c.PrintfLine("250-fake\r\n250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			c.PrintfLine("220 go ahead")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, c, encrypted = tlsConn, textproto.NewConn(tlsConn), true
+		case "AUTH":
+			auth, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			s.mu.Lock()
+			s.auth = string(auth)
+			s.mu.Unlock()
+			c.PrintfLine("235 authenticated")
+		case "DATA":
+			c.PrintfLine("354 go ahead")
+			msg, err := io.ReadAll(c.DotReader())
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.msgs = append(s.msgs, string(msg))
+			s.encrypted = append(s.encrypted, encrypted)
+			s.mu.Unlock()
+			c.PrintfLine("250 queued")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			return
+		default:
+			c.PrintfLine("250 ok")
+		}
+	}
+}
+
+func (s *fakeSMTP) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.msgs...)
+}
+
+// Send an alert for status through s and parse the message it got.
+func sendTestEmail(t *testing.T, s *fakeSMTP, config Config, status *TargetStatus) *mail.Message {
+	config.SMTP = SMTPConfig{Hostname: "127.0.0.1", Port: s.port, Security: "none"}
+	config.Alert.FromEmail = "pingo2@example.com"
+	config.Alert.ToEmail = "ops@example.com"
+	if err := EmailAlert(*status, config); err != nil {
+		t.Fatal(err)
+	}
+	msgs := s.messages()
+	if len(msgs) != 1 {
+		t.Fatalf("%d messages sent, want 1", len(msgs))
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(msgs[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestEmailMultipart(t *testing.T) {
+	config := Config{Timeout: 5}
+	config.Alert.HTMLBody = `<b style="color:red">{{.Target.Name}} {{.State}}</b> <a href="{{.StatusURL}}">status</a>`
+	config.Alert.StatusURL = "http://pingo2.example.com/"
+	msg := sendTestEmail(t, newFakeSMTP(t), config, downStatus(&Target{Id: 1, Name: "web", Addr: "http://web"}))
+
+	if got := msg.Header.Get("Subject"); got != "Host DOWN: web" {
+		t.Errorf("Subject %q", got)
+	}
+	if got := msg.Header.Get("From"); got != "pingo2@example.com" {
+		t.Errorf("From %q", got)
+	}
+	if got := msg.Header.Get("To"); got != "ops@example.com" {
+		t.Errorf("To %q", got)
+	}
+	if got := msg.Header.Get("Mime-Version"); got != "1.0" {
+		t.Errorf("MIME-Version %q", got)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "multipart/alternative" || params["boundary"] == "" {
+		t.Fatalf("Content-Type %s %v", mediaType, params)
+	}
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	var types, bodies []string
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		types = append(types, partType)
+		bodies = append(bodies, string(body))
+	}
+	// the last alternative is the preferred one
+	if len(types) != 2 || types[0] != "text/plain" || types[1] != "text/html" {
+		t.Fatalf("parts %v, want text/plain then text/html", types)
+	}
+	if !strings.Contains(bodies[0], `"Name": "web"`) {
+		t.Errorf("text part lacks the status:\n%s", bodies[0])
+	}
+	if want := `<b style="color:red">web down</b> <a href="http://pingo2.example.com/">status</a>`; bodies[1] != want {
+		t.Errorf("HTML part %q, want %q", bodies[1], want)
+	}
+}
+
+func TestEmailPlainWithoutHTMLBody(t *testing.T) {
+	msg := sendTestEmail(t, newFakeSMTP(t), Config{Timeout: 5}, downStatus(&Target{Id: 1, Name: "web", Addr: "http://web"}))
+	mediaType, _, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "text/plain" {
+		t.Errorf("Content-Type %s without HTMLBody, want text/plain", mediaType)
+	}
+}
+
+func TestEmailTemplates(t *testing.T) {
+	config := Config{Timeout: 5}
+	config.Alert.SubjectTemplate = "[{{.Target.Name}}]\n is {{if .Online}}UP{{else}}DOWN{{end}}"
+	config.Alert.BodyTemplate = "{{.Target.Name}} is {{.State}}{{if not .Online}}: {{.ErrorMsg}}{{end}}"
+	target := &Target{Id: 1, Name: "web", Addr: "http://web"}
+
+	down := downStatus(target)
+	up := &TargetStatus{Target: target, Online: true, State: "up", Since: down.Since, LastCheck: down.Since}
+	tests := []struct {
+		status        *TargetStatus
+		subject, body string
+	}{
+		// a header stays on one line
+		{down, "[web] is DOWN", "web is down: connection refused"},
+		{up, "[web] is UP", "web is up"},
+	}
+	for _, tt := range tests {
+		msg := sendTestEmail(t, newFakeSMTP(t), config, tt.status)
+		if got := msg.Header.Get("Subject"); got != tt.subject {
+			t.Errorf("Subject %q, want %q", got, tt.subject)
+		}
+		body, err := io.ReadAll(msg.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(string(body)); got != tt.body {
+			t.Errorf("body %q, want %q", got, tt.body)
+		}
+	}
+
+	// the target's own templates win
+	override := &Target{Id: 2, Name: "db", Addr: "tcp://db:5432", SubjectTemplate: "db alert", BodyTemplate: "see {{.StatusURL}}"}
+	config.Alert.StatusURL = "http://status/"
+	msg := sendTestEmail(t, newFakeSMTP(t), config, downStatus(override))
+	body, _ := io.ReadAll(msg.Body)
+	if got := msg.Header.Get("Subject"); got != "db alert" || strings.TrimSpace(string(body)) != "see http://status/" {
+		t.Errorf("target templates not used: %q %q", got, body)
+	}
+
+	// a template failing to render is an error, not a blank email
+	config.Alert.BodyTemplate = "{{.Nope}}"
+	config.SMTP = SMTPConfig{Hostname: "127.0.0.1", Port: 1, Security: "none"}
+	if err := EmailAlert(*down, config); err == nil || !strings.Contains(err.Error(), "rendering alert body") {
+		t.Errorf("got %v for a body template that can't render", err)
+	}
+}
+
+func TestSMTPStartTLS(t *testing.T) {
+	// httptest's certificate is valid for 127.0.0.1
+	certs := httptest.NewTLSServer(nil)
+	certs.Close()
+	smtpRootCAs = x509.NewCertPool()
+	smtpRootCAs.AddCert(certs.Certificate())
+	defer func() { smtpRootCAs = nil }()
+
+	s := newFakeSMTP(t)
+	s.tls = certs.TLS
+	config := Config{Timeout: 5}
+	config.Alert.FromEmail = "pingo2@example.com"
+	config.Alert.ToEmail = "ops@example.com"
+	// STARTTLS is used when offered, required with "starttls"
+	for _, security := range []string{"starttls", ""} {
+		config.SMTP = SMTPConfig{Hostname: "127.0.0.1", Port: s.port, User: "pingo2", Password: "secret", Security: security}
+		if err := EmailAlert(*downStatus(&Target{Id: 1, Name: "web"}), config); err != nil {
+			t.Fatalf("Security %q: %s", security, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.msgs) != 2 || !s.encrypted[0] || !s.encrypted[1] {
+		t.Errorf("messages %d, encrypted %v", len(s.msgs), s.encrypted)
+	}
+	if s.auth != "\x00pingo2\x00secret" {
+		t.Errorf("AUTH PLAIN sent %q", s.auth)
+	}
+}
+
+func TestSMTPStartTLSRequired(t *testing.T) {
+	s := newFakeSMTP(t)
+	_, err := dialSMTP("127.0.0.1", s.port, SMTPConfig{Security: "starttls"})
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("got %v from a server without STARTTLS", err)
+	}
+	if len(s.messages()) != 0 {
+		t.Error("message sent unencrypted")
+	}
+}
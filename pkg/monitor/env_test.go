@@ -0,0 +1,57 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("PINGO2_TEST_PASSWORD", "s3cret")
+	t.Setenv("PINGO2_TEST_TOKEN", "tok")
+	doc := `{
+		"Timeout": 7,
+		"SMTP": {"Hostname": "mail", "Port": 587, "Password": "${PINGO2_TEST_PASSWORD}"},
+		"Alert": {"PagerDutyKey": "${PINGO2_TEST_TOKEN}"},
+		"Targets": [{
+			"Name": "web",
+			"Addr": "https://example.com/",
+			"Interval": 60,
+			"FollowRedirects": true,
+			"Headers": {"Authorization": "Bearer ${PINGO2_TEST_TOKEN}"},
+			"Keyword": "price: $5",
+			"ExpectStatus": [200]
+		}]
+	}`
+	config := decodeTestConfig(t, doc, false)
+	if config.SMTP.Password != "s3cret" || config.Alert.PagerDutyKey != "tok" {
+		t.Errorf("references not expanded: %q %q", config.SMTP.Password, config.Alert.PagerDutyKey)
+	}
+	target := config.Targets[0]
+	if got := target.Headers["Authorization"]; got != "Bearer tok" {
+		t.Errorf("header %q", got)
+	}
+	if target.Keyword != "price: $5" {
+		t.Errorf("bare $ changed: %q", target.Keyword)
+	}
+	// non-string fields are left alone
+	if config.Timeout != 7 || config.SMTP.Port != 587 || target.Interval != 60 ||
+		target.FollowRedirects == nil || !*target.FollowRedirects || len(target.ExpectStatus) != 1 || target.ExpectStatus[0] != 200 {
+		t.Errorf("non-string fields changed: %+v", config)
+	}
+}
+
+func TestExpandEnvUndefined(t *testing.T) {
+	t.Setenv("PINGO2_TEST_SET", "x")
+	var config Config
+	config.SMTP.Password = "${PINGO2_TEST_UNSET_B}"
+	config.Alert.PagerDutyKey = "${PINGO2_TEST_UNSET_A}${PINGO2_TEST_SET}"
+	config.Targets = []Target{{Name: "${PINGO2_TEST_UNSET_A}"}}
+	err := expandEnv(&config)
+	if err == nil {
+		t.Fatal("undefined variables accepted")
+	}
+	// each name once, sorted
+	if want := "PINGO2_TEST_UNSET_A, PINGO2_TEST_UNSET_B"; !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("error %q, want it to list %s", err, want)
+	}
+}
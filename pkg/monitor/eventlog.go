@@ -0,0 +1,158 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// megabytes written to Config.EventLog before it is rotated, when
+// Config.EventLogMaxSize is not set
+const EventLogMaxSize = 10
+
+// rotated event logs kept, as EventLog.1 (the newest) to EventLog.3
+const EventLogBackups = 3
+
+// A line of Config.EventLog.
+type event struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	TargetId int       `json:"target_id"`
+	Name     string    `json:"name"`
+	Addr     string    `json:"addr"`
+	State    string    `json:"state"`
+	// state_change: the state before
+	From string `json:"from,omitempty"`
+	// alerts: the transition alerted and where to
+	Transition string `json:"transition,omitempty"`
+	Channel    string `json:"channel,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// The audit trail of target transitions and alerts, see Config.EventLog.
+var eventLog = struct {
+	sync.Mutex
+	path    string
+	maxSize int64
+	file    *os.File
+	// bytes in file
+	size int64
+}{}
+
+// Write events to Config.EventLog from now on, none when it is empty.
+// The previous file stays in use if the new one can't be opened.
+func openEventLog(config Config) error {
+	maxSize := int64(config.EventLogMaxSize)
+	if maxSize <= 0 {
+		maxSize = EventLogMaxSize
+	}
+	maxSize <<= 20
+
+	eventLog.Lock()
+	defer eventLog.Unlock()
+	if config.EventLog == eventLog.path && (eventLog.file != nil || config.EventLog == "") {
+		eventLog.maxSize = maxSize
+		return nil
+	}
+	var file *os.File
+	var size int64
+	if config.EventLog != "" {
+		var err error
+		file, err = os.OpenFile(config.EventLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		if info, err := file.Stat(); err == nil {
+			size = info.Size()
+		}
+	}
+	if eventLog.file != nil {
+		eventLog.file.Close()
+	}
+	eventLog.path, eventLog.maxSize, eventLog.file, eventLog.size = config.EventLog, maxSize, file, size
+	return nil
+}
+
+// Record the target changing state, from the previous one.
+func logTransition(status *TargetStatus, from string) {
+	e := targetEvent(status, "state_change")
+	e.From = from
+	if !status.Online {
+		e.Error = status.ErrorMsg
+	}
+	writeEvent(e)
+}
+
+// Record an alert sent through channel, or failing with err.
+func logAlert(status *TargetStatus, channel string, err error) {
+	e := targetEvent(status, "alert_sent")
+	e.Transition = alertEvent(status)
+	e.Channel = channel
+	if err != nil {
+		e.Event = "alert_failed"
+		e.Error = err.Error()
+	}
+	writeEvent(e)
+}
+
+func targetEvent(status *TargetStatus, kind string) event {
+	return event{
+		Time:     time.Now(),
+		Event:    kind,
+		TargetId: status.Target.Id,
+		Name:     status.Target.Name,
+		Addr:     status.Target.Addr,
+		State:    status.State,
+	}
+}
+
+func writeEvent(e event) {
+	eventLog.Lock()
+	defer eventLog.Unlock()
+	if eventLog.file == nil {
+		return
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		slog.Error("event could not be encoded", "event", "event_log_error", "error", err)
+		return
+	}
+	line = append(line, '\n')
+
+	if eventLog.size > 0 && eventLog.size+int64(len(line)) > eventLog.maxSize {
+		if err := rotateEventLog(); err != nil {
+			slog.Error("event log could not be rotated", "event", "event_log_error", "file", eventLog.path, "error", err)
+		}
+	}
+	if eventLog.file == nil {
+		return
+	}
+	n, err := eventLog.file.Write(line)
+	eventLog.size += int64(n)
+	if err != nil {
+		slog.Error("event log write error", "event", "event_log_error", "file", eventLog.path, "error", err)
+	}
+}
+
+// Shift the backups along, move the full log to EventLog.1 and start a
+// new one. Caller must hold the lock.
+func rotateEventLog() error {
+	path := eventLog.path
+	eventLog.file.Close()
+	for i := EventLogBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+	}
+	renameErr := os.Rename(path, path+".1")
+
+	// on error, events are dropped until the next reload
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		eventLog.file = nil
+		return err
+	}
+	eventLog.file, eventLog.size = file, 0
+	return renameErr
+}
@@ -0,0 +1,63 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// output of a check command kept for ErrorMsg, in bytes
+const MaxCheckOutput = 4096
+
+// Run an exec:// target's CheckCommand with bash, Nagios style: exit code
+// 0 is up, anything else down with the command's output as the error.
+// The command is killed at the ctx deadline.
+func checkCommand(ctx context.Context, command string) error {
+	var out bytes.Buffer
+	w := &limitedWriter{w: &out, n: MaxCheckOutput}
+	cmd := exec.CommandContext(ctx, "/bin/bash", "-c", command)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	// kill whatever the command forked too, not just bash
+	killProcessGroup(cmd)
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("check command timed out")
+	}
+	if err == nil {
+		return nil
+	}
+	msg := strings.TrimSpace(out.String())
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if msg == "" {
+			return fmt.Errorf("check command exited with %d", exitErr.ExitCode())
+		}
+		return fmt.Errorf("exit code %d: %s", exitErr.ExitCode(), msg)
+	}
+	return fmt.Errorf("check command could not be run, %s", err)
+}
+
+// Writer keeping the first n bytes, discarding the rest.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.n > 0 {
+		keep := p
+		if len(keep) > l.n {
+			keep = keep[:l.n]
+		}
+		l.n -= len(keep)
+		if _, err := l.w.Write(keep); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,153 @@
+package monitor
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// most targets a single config entry may expand to
+const MaxExpandedTargets = 256
+
+// CIDR block as the host of an address, e.g. "10.0.0.0/28:22/path" or
+// "[fd00::/124]:22"
+var cidrHost = regexp.MustCompile(`^\[?([0-9a-fA-F.:]+/[0-9]{1,3})\]?((?::[0-9]+)?(?:[/?#].*)?)$`)
+
+// Split targets whose Addr has a comma separated list of hosts, e.g.
+// "ping://db1,db2", or a CIDR block, e.g. "ping://10.0.0.0/28", into one
+// target per host. The host is appended to their name, all other fields
+// are copied. DependsOn ids are carried over to the new positions, which
+// are returned by config id, nil when nothing was expanded.
+func expandTargets(targets []Target) ([]Target, map[int][]int, error) {
+	var out []Target
+	ids := make(map[int][]int)
+	expanded := false
+	for i, t := range targets {
+		hosts, rest, err := expandAddr(t.Addr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("target %d (%s): %s", i+1, t.Name, err)
+		}
+		if hosts == nil {
+			out = append(out, t)
+			ids[i+1] = []int{len(out)}
+			continue
+		}
+		expanded = true
+		scheme := t.Addr[:strings.Index(t.Addr, "://")+3]
+		for _, host := range hosts {
+			c := t
+			c.Addr = scheme + host + rest
+			c.Name = strings.TrimSpace(t.Name + " " + strings.Trim(host, "[]"))
+			out = append(out, c)
+			ids[i+1] = append(ids[i+1], len(out))
+		}
+	}
+	if !expanded {
+		return targets, nil, nil
+	}
+
+	for i := range out {
+		if len(out[i].DependsOn) == 0 {
+			continue
+		}
+		deps := make([]int, len(out[i].DependsOn))
+		for j, dep := range out[i].DependsOn {
+			if dep < 1 || dep > len(targets) {
+				return nil, nil, fmt.Errorf("target %s: DependsOn %d is not another target", out[i].Addr, dep)
+			} else if len(ids[dep]) != 1 {
+				return nil, nil, fmt.Errorf("target %s: DependsOn %d is an address list or range, not a single target", out[i].Addr, dep)
+			}
+			deps[j] = ids[dep][0]
+		}
+		out[i].DependsOn = deps
+	}
+	return out, ids, nil
+}
+
+// Hosts of an address with a list or range, and what follows them.
+// No hosts for a plain address.
+func expandAddr(addr string) (hosts []string, rest string, err error) {
+	i := strings.Index(addr, "://")
+	if i < 0 {
+		return nil, "", nil
+	}
+	after := addr[i+3:]
+
+	if m := cidrHost.FindStringSubmatch(after); m != nil && isBlock(m[1]) {
+		hosts, err = cidrHosts(m[1])
+		return hosts, m[2], err
+	}
+
+	end := strings.IndexAny(after, "/?#")
+	if end < 0 {
+		end = len(after)
+	}
+	if !strings.Contains(after[:end], ",") {
+		return nil, "", nil
+	}
+	for _, h := range strings.Split(after[:end], ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	if len(hosts) > MaxExpandedTargets {
+		return nil, "", fmt.Errorf("%d addresses, more than the %d allowed", len(hosts), MaxExpandedTargets)
+	}
+	return hosts, after[end:], nil
+}
+
+// Whether a host matching cidrHost is meant as a block: an IP address,
+// not a hostname like "cafe/10", and the block's network address, as
+// "10.0.0.1/24" reads as a host and a path. A bad prefix length still
+// counts, for cidrHosts to report it.
+func isBlock(cidr string) bool {
+	ip := net.ParseIP(cidr[:strings.Index(cidr, "/")])
+	if ip == nil {
+		return false
+	}
+	_, block, err := net.ParseCIDR(cidr)
+	return err != nil || ip.Equal(block.IP)
+}
+
+// Every host address of the block, without the network and broadcast
+// addresses of IPv4 blocks larger than /31.
+func cidrHosts(cidr string) ([]string, error) {
+	ip, block, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := block.Mask.Size()
+	if bits-ones > 16 || 1<<(bits-ones) > MaxExpandedTargets+2 {
+		return nil, fmt.Errorf("%s has more than the %d addresses allowed", cidr, MaxExpandedTargets)
+	}
+
+	v4 := ip.To4() != nil
+	var hosts []string
+	for cur := block.IP.Mask(block.Mask); block.Contains(cur); cur = nextIP(cur) {
+		if v4 {
+			hosts = append(hosts, cur.String())
+		} else {
+			hosts = append(hosts, "["+cur.String()+"]")
+		}
+	}
+	if v4 && bits-ones > 1 {
+		hosts = hosts[1 : len(hosts)-1]
+	}
+	if len(hosts) > MaxExpandedTargets {
+		return nil, fmt.Errorf("%s has more than the %d addresses allowed", cidr, MaxExpandedTargets)
+	}
+	return hosts, nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
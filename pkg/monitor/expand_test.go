@@ -0,0 +1,38 @@
+package monitor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandAddr(t *testing.T) {
+	tests := []struct {
+		addr  string
+		hosts []string
+		rest  string
+		err   bool
+	}{
+		{addr: "http://example.com/health"},
+		{addr: "http://db/2"},
+		{addr: "http://cafe/10"},
+		{addr: "http://10.0.0.1/24/health"},
+		{addr: "http://10.0.0.1/health"},
+		{addr: "ping://10.0.0.0/30", hosts: []string{"10.0.0.1", "10.0.0.2"}},
+		{addr: "http://10.0.0.0/31:8080/health", hosts: []string{"10.0.0.0", "10.0.0.1"}, rest: ":8080/health"},
+		{addr: "tcp://[fd00::/127]:22", hosts: []string{"[fd00::]", "[fd00::1]"}, rest: ":22"},
+		{addr: "tcp://db1:5432,db2:5432", hosts: []string{"db1:5432", "db2:5432"}},
+		{addr: "http://a,b/health?x=1", hosts: []string{"a", "b"}, rest: "/health?x=1"},
+		{addr: "ping://10.0.0.0/33", err: true},
+		{addr: "ping://10.0.0.0/8", err: true},
+	}
+	for _, tt := range tests {
+		hosts, rest, err := expandAddr(tt.addr)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error %v, want error %v", tt.addr, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(hosts, tt.hosts) || rest != tt.rest {
+			t.Errorf("%s: got %q %q, want %q %q", tt.addr, hosts, rest, tt.hosts, tt.rest)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package monitor
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Stat a local file for a file:// target. Fails when it's missing, not a
+// regular file, older than maxAge seconds or smaller than minSize bytes,
+// either check skipped when 0.
+func checkFile(path string, maxAge int, minSize int64) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", path)
+	}
+	if age := time.Since(info.ModTime()); maxAge > 0 && age > time.Duration(maxAge)*time.Second {
+		return fmt.Errorf("%s not modified for %s, since %s", path, age.Round(time.Second), info.ModTime().Format(time.RFC3339))
+	}
+	if minSize > 0 && info.Size() < minSize {
+		return fmt.Errorf("%s is %d bytes, expected at least %d", path, info.Size(), minSize)
+	}
+	return nil
+}
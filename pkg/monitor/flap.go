@@ -0,0 +1,54 @@
+package monitor
+
+import (
+	"time"
+)
+
+// Detects a target going up and down too often, see Config.Flap.
+type flapDetector struct {
+	FlapConfig
+	transitions []time.Time
+	flapping    bool
+}
+
+type FlapConfig struct {
+	// State changes within Window seconds for a target to be flapping,
+	// 0 disables flap detection
+	Threshold int
+	Window    int
+	// Seconds without a state change before a flapping target is
+	// considered stable again
+	Quiet int
+}
+
+// Record a state change at now, returns whether the target is flapping.
+func (f *flapDetector) transition(now time.Time) bool {
+	if f.Threshold <= 0 {
+		return false
+	}
+	cutoff := now.Add(-time.Duration(f.Window) * time.Second)
+	kept := f.transitions[:0]
+	for _, t := range f.transitions {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	f.transitions = append(kept, now)
+	if len(f.transitions) > f.Threshold {
+		f.flapping = true
+	}
+	return f.flapping
+}
+
+// Returns true when a flapping target has been stable for the quiet period.
+func (f *flapDetector) settle(now time.Time) bool {
+	if !f.flapping || len(f.transitions) == 0 {
+		return false
+	}
+	if now.Sub(f.transitions[len(f.transitions)-1]) < time.Duration(f.Quiet)*time.Second {
+		return false
+	}
+	f.flapping = false
+	f.transitions = nil
+	return true
+}
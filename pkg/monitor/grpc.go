@@ -0,0 +1,64 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Call grpc.health.v1.Health/Check on a grpc(s) target, for service or the
+// whole server when empty. Fails unless the answer is SERVING.
+func checkGRPC(ctx context.Context, t *Target, addrURL *url.URL, service string) error {
+	host := addrURL.Host
+	if addrURL.Port() == "" {
+		if addrURL.Scheme == "grpcs" {
+			host = net.JoinHostPort(addrURL.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(addrURL.Hostname(), "80")
+		}
+	}
+
+	creds := insecure.NewCredentials()
+	if addrURL.Scheme == "grpcs" {
+		serverName := addrURL.Hostname()
+		if t.Host != "" {
+			serverName = t.Host
+		}
+		creds = credentials.NewTLS(&tls.Config{ServerName: serverName, InsecureSkipVerify: t.InsecureSkipVerify})
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return t.dialer().DialContext(ctx, "tcp", addr)
+		}),
+	}
+	if t.Host != "" {
+		opts = append(opts, grpc.WithAuthority(t.Host))
+	}
+	if t.UserAgent != "" {
+		opts = append(opts, grpc.WithUserAgent(t.UserAgent))
+	}
+
+	// passthrough, the dialer resolves through the target's resolver
+	conn, err := grpc.NewClient("passthrough:///"+host, opts...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return nil
+}
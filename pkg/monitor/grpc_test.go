@@ -0,0 +1,46 @@
+package monitor
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestGRPCHealth(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus("db", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(srv, healthSrv)
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	addrURL, _ := url.Parse("grpc://" + ln.Addr().String())
+	tests := []struct {
+		service, err string
+	}{
+		{"", ""},
+		{"db", "health check returned NOT_SERVING"},
+		{"cache", "NotFound"},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := checkGRPC(ctx, &Target{Name: "grpc"}, addrURL, tt.service)
+		cancel()
+		if tt.err == "" && err != nil {
+			t.Errorf("service %q: %s", tt.service, err)
+		} else if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("service %q: got %v, want %s", tt.service, err, tt.err)
+		}
+	}
+}
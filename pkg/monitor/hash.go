@@ -0,0 +1,18 @@
+package monitor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SHA-256 of a response body, hex encoded. With normalize set, runs of
+// whitespace are collapsed and leading/trailing whitespace is dropped first,
+// so reformatting alone doesn't count as a content change.
+func bodyHash(body []byte, normalize bool) string {
+	if normalize {
+		body = bytes.Join(bytes.Fields(body), []byte(" "))
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
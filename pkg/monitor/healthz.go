@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// seconds a check may run late, on top of its own duration, before
+// /healthz reports a stall
+const HealthzGrace = 60
+
+// A check scheduled by a target runner, and how late it may run.
+type dueCheck struct {
+	name  string
+	at    time.Time
+	grace time.Duration
+}
+
+// Next check of every running target, keyed by the runner's own copy of
+// the target so a reloaded runner doesn't clear its successor.
+var dueChecks = struct {
+	sync.Mutex
+	next map[*Target]dueCheck
+}{next: make(map[*Target]dueCheck)}
+
+// Record when the target's next check is due, whatever decided it: the
+// interval, Config.Breaker, Retry-After or Target.Schedule.
+func scheduleCheck(t *Target, at time.Time, config Config) {
+	// the check itself, including confirming a recovery
+	checks := time.Duration(1 + t.ConfirmRecovery)
+	grace := HealthzGrace*time.Second + checks*t.timeout(config) + (checks-1)*time.Duration(t.RetryDelay)*time.Second
+	dueChecks.Lock()
+	defer dueChecks.Unlock()
+	dueChecks.next[t] = dueCheck{name: t.Name, at: at, grace: grace}
+}
+
+// Forget the runner's schedule once it stopped.
+func unscheduleCheck(t *Target) {
+	dueChecks.Lock()
+	defer dueChecks.Unlock()
+	delete(dueChecks.next, t)
+}
+
+// The most overdue check, if any is late by more than its grace.
+func overdueCheck(now time.Time) (dueCheck, time.Duration, bool) {
+	dueChecks.Lock()
+	defer dueChecks.Unlock()
+	var worst dueCheck
+	var late time.Duration
+	for _, c := range dueChecks.next {
+		if l := now.Sub(c.at); l > c.grace && l > late {
+			worst, late = c, l
+		}
+	}
+	return worst, late, late > 0
+}
+
+// GET /healthz, whether pingo2 itself works: 200 while every target gets
+// checked when due, 503 once a check is overdue by more than HealthzGrace
+// plus its timeout. Down targets don't matter, only a stalled pipeline does.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	if c, late, ok := overdueCheck(time.Now()); ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "stalled, check of %s overdue by %s\n", c.name, late.Round(time.Second))
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,39 @@
+package monitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthzOverdueCheck(t *testing.T) {
+	config := Config{Timeout: 5}
+	target := &Target{Name: "late", RetryDelay: RetryDelay}
+	defer unscheduleCheck(target)
+
+	tests := []struct {
+		due  time.Duration
+		code int
+	}{
+		// a long Breaker or Retry-After wait is no stall
+		{time.Hour, http.StatusOK},
+		{-30 * time.Second, http.StatusOK},
+		{-(HealthzGrace + 10) * time.Second, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		scheduleCheck(target, time.Now().Add(tt.due), config)
+		w := httptest.NewRecorder()
+		healthzHandler(w, httptest.NewRequest("GET", "/healthz", nil))
+		if w.Code != tt.code {
+			t.Errorf("due in %s: got %d, want %d: %s", tt.due, w.Code, tt.code, w.Body)
+		}
+	}
+
+	unscheduleCheck(target)
+	w := httptest.NewRecorder()
+	healthzHandler(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("stopped runner still reported: %d", w.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// default seconds between Config.Heartbeat pings
+const HeartbeatInterval = 60
+
+// Ping Config.Heartbeat every HeartbeatInterval while pingo2 runs, so a
+// dead man's switch elsewhere notices when it stops.
+func startHeartbeat(ctx context.Context, wg *sync.WaitGroup, config Config) {
+	if config.Heartbeat == "" {
+		return
+	}
+	interval := time.Duration(config.HeartbeatInterval) * time.Second
+	if interval <= 0 {
+		interval = HeartbeatInterval * time.Second
+	}
+	client := &http.Client{Timeout: time.Duration(config.Timeout) * time.Second}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := heartbeat(ctx, client, config.Heartbeat); err != nil {
+				// the URL itself is often the secret, it isn't logged
+				slog.Error("heartbeat failed", "event", "heartbeat_failed", "error", err)
+			} else {
+				slog.Debug("heartbeat sent", "event", "heartbeat")
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func heartbeat(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.CopyN(ioutil.Discard, resp.Body, 64*1024)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// longest Retry-After a checked target can ask for
+const MaxRetryAfter = time.Hour
+
+// Retry defaults for email and HTTP based alerts (Slack, webhooks...), in
+// seconds
+const (
+	AlertRetryAttempts = 3
+	AlertRetryDelay    = 1
+	AlertRetryMaxDelay = 30
+	AlertRetryMaxTotal = 60
+)
+
+// Retry send with exponential backoff, as set by Alert.Retry. send returns
+// a wait to use instead of the computed delay, e.g. from Retry-After, and
+// whether its error is worth retrying. Gives up when attempts run out or
+// the next wait would exceed the total retry time, so the alert routine
+// isn't held up for long.
+func retryAlert(name string, config Config, send func() (wait time.Duration, retry bool, err error)) error {
+	retry := config.Alert.Retry
+	if retry.MaxAttempts <= 0 {
+		retry.MaxAttempts = AlertRetryAttempts
+	}
+	if retry.BaseDelay <= 0 {
+		retry.BaseDelay = AlertRetryDelay
+	}
+	if retry.MaxDelay <= 0 {
+		retry.MaxDelay = AlertRetryMaxDelay
+	}
+	if retry.MaxTotal <= 0 {
+		retry.MaxTotal = AlertRetryMaxTotal
+	}
+	maxDelay := time.Duration(retry.MaxDelay) * time.Second
+	deadline := time.Now().Add(time.Duration(retry.MaxTotal) * time.Second)
+
+	delay := time.Duration(retry.BaseDelay) * time.Second
+	for attempt := 1; ; attempt++ {
+		wait, retryable, err := send()
+		if err == nil {
+			return nil
+		}
+		if !retryable {
+			return fmt.Errorf("error sending %s alert, err %s", name, err)
+		}
+		if wait == 0 {
+			wait = delay
+			delay *= 2
+			if delay > maxDelay {
+				delay = maxDelay
+			}
+		}
+
+		if attempt >= retry.MaxAttempts || time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("error sending %s alert after %d attempts, err %s", name, attempt, err)
+		}
+		slog.Debug("alert attempt failed", "event", "alert_retry", "channel", name, "attempt", attempt, "error", err, "retry_in", wait)
+		time.Sleep(wait)
+	}
+}
+
+// POST body to url with the extra headers, retrying on network errors and
+// 429/5xx responses. A Retry-After header takes precedence over the
+// computed delay.
+func postAlert(name string, url string, contentType string, headers map[string]string, body []byte, config Config) error {
+	client := &http.Client{Timeout: time.Duration(config.Timeout) * time.Second}
+	return retryAlert(name, config, func() (time.Duration, bool, error) {
+		req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			return 0, false, err
+		}
+		req.Header.Set("Content-Type", contentType)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, true, err
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode < 300 {
+			return 0, false, nil
+		}
+		err = fmt.Errorf("unexpected status %s", resp.Status)
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			// client error, retrying won't help
+			return 0, false, err
+		}
+		wait, _ := parseRetryAfter(resp.Header.Get("Retry-After"))
+		return wait, true, err
+	})
+}
+
+// Parse a Retry-After header, given either in seconds or as an HTTP date.
+func parseRetryAfter(h string) (time.Duration, bool) {
+	if h == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(h); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
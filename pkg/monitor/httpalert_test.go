@@ -0,0 +1,26 @@
+package monitor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryAlertLogsAttemptsAtDebug(t *testing.T) {
+	buf := captureLog(t)
+	config := Config{}
+	config.Alert.Retry.MaxAttempts = 3
+	attempts := 0
+	err := retryAlert("webhook", config, func() (time.Duration, bool, error) {
+		attempts++
+		return time.Millisecond, true, errors.New("503 Service Unavailable")
+	})
+	if attempts != 3 || err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Fatalf("%d attempts, got %v", attempts, err)
+	}
+	out := buf.String()
+	if strings.Count(out, "level=DEBUG msg=\"alert attempt failed\"") != 2 || strings.Contains(out, "level=WARN") {
+		t.Errorf("attempts not logged at debug:\n%s", out)
+	}
+}
@@ -0,0 +1,333 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Check an http(s) target, recording any error in status. The request,
+// including reading the body, must be done by the ctx deadline.
+func checkHTTP(ctx context.Context, t *Target, addrURL *url.URL, status *TargetStatus, maxBody int64, capture int) (failed bool) {
+	tlog := targetLog(t)
+	var resp *http.Response
+	var body []byte
+	bodyRead := false
+	truncated := false
+	// one byte past the limit tells whether there was more
+	readBody := func() error {
+		bodyRead = true
+		var err error
+		body, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxBody+1))
+		if int64(len(body)) > maxBody {
+			body = body[:maxBody]
+			truncated = true
+			tlog.Debug("response body truncated", "event", "body_truncated", "bytes", maxBody)
+		}
+		return err
+	}
+	fail := func(msg string) bool {
+		if truncated {
+			msg = fmt.Sprintf("%s (body truncated to %d bytes)", msg, maxBody)
+		}
+		if capture > 0 && resp != nil && resp.Request.Method != http.MethodHead {
+			// see Config.CaptureFailureBody
+			if !bodyRead {
+				readBody()
+			}
+			snippet := body
+			if len(snippet) > capture {
+				snippet = snippet[:capture]
+			}
+			status.FailureBody = string(snippet)
+		}
+		status.ErrorMsg = msg
+		tlog.Warn("http(s) error", "event", "check_failed", "error", msg)
+		return true
+	}
+	failErr := func(err error) bool {
+		status.FailureClass = classifyError(err)
+		return fail(fmt.Sprintf("%s", err))
+	}
+
+	method := t.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var reqBody io.Reader
+	if t.Body != "" {
+		reqBody = strings.NewReader(t.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, addrURL.String(), reqBody)
+	if err != nil {
+		return fail(fmt.Sprintf("%s", err))
+	}
+
+	if t.UserAgent != "" {
+		req.Header.Set("User-Agent", t.UserAgent)
+	}
+	for k, v := range t.Headers {
+		// net/http ignores a Host header, and Target.Host wins anyway
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+
+	if t.Username != "" && t.Password != "" {
+		req.SetBasicAuth(t.Username, t.Password)
+	}
+
+	if t.Host != "" {
+		req.Host = t.Host
+	}
+	transport := t.transport
+	if transport == nil {
+		transport, err = httpTransport(t)
+		if err != nil {
+			return fail(fmt.Sprintf("%s", err))
+		}
+		if t.ReuseConnections != nil && *t.ReuseConnections {
+			t.transport = transport
+		}
+	}
+	client := &http.Client{Transport: transport}
+	if t.FollowRedirects != nil && !*t.FollowRedirects {
+		// let ExpectStatus and keywords see the redirect itself
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	resp, err = client.Do(req)
+	if err != nil {
+		resp = nil
+		if isProxyError(err) {
+			status.FailureClass = classifyError(err)
+			return fail(fmt.Sprintf("proxy unreachable, %s", err))
+		}
+		return failErr(err)
+	}
+	defer func() {
+		if t.transport != nil {
+			// read what's left so the connection can be reused
+			io.CopyN(ioutil.Discard, resp.Body, 64*1024)
+		}
+		resp.Body.Close()
+	}()
+
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		status.CertExpiry = resp.TLS.PeerCertificates[0].NotAfter
+		left := time.Until(status.CertExpiry)
+		if t.CertWarnDays > 0 && left < time.Duration(t.CertWarnDays)*24*time.Hour {
+			status.CertWarning = true
+			status.ErrorMsg = fmt.Sprintf("certificate expires in %d days, on %s", int(left.Hours()/24), status.CertExpiry.Format("2006-01-02"))
+			tlog.Warn("certificate warning", "event", "cert_warning", "expiry", status.CertExpiry)
+		}
+	}
+
+	if resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusTooManyRequests {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if d > MaxRetryAfter {
+				d = MaxRetryAfter
+			}
+			t.retryAfter = d
+		}
+	}
+	if !expectedStatus(t.ExpectStatus, resp.StatusCode) {
+		return fail(fmt.Sprintf("unexpected status %d", resp.StatusCode))
+	}
+	for name, want := range t.ExpectHeaders {
+		values, ok := resp.Header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return fail(fmt.Sprintf("header '%s' missing", name))
+		}
+		if got := strings.Join(values, ", "); !strings.Contains(got, want) {
+			return fail(fmt.Sprintf("header '%s: %s' does not contain '%s'", name, got, want))
+		}
+	}
+	if method == http.MethodHead {
+		// no body to look at
+		return false
+	}
+
+	if err := readBody(); err != nil {
+		if ctx.Err() != nil {
+			// e.g. a body trickling in
+			err = fmt.Errorf("body not read before the timeout, got %d bytes, %w", len(body), err)
+		}
+		return failErr(err)
+	}
+	// keywords are matched in the decoded text, hashes are of the raw body
+	text := decodeBody(t, resp.Header.Get("Content-Type"), body)
+	if t.WatchChanges {
+		// any change matters, not a keyword
+	} else if t.Keyword != "" && t.KeywordRegex {
+		re, err := t.keywordRegexp()
+		if err != nil {
+			return fail(fmt.Sprintf("invalid keyword regex '%s', %s", t.Keyword, err))
+		}
+		if !re.Match(text) {
+			return fail(fmt.Sprintf("regex '%s' not matched", t.Keyword))
+		}
+	} else if t.Keyword != "" && !bytes.Contains(text, []byte(t.Keyword)) {
+		return fail(fmt.Sprintf("keyword '%s' not found", t.Keyword))
+	}
+	if len(t.Keywords) > 0 && !t.WatchChanges {
+		var missing []string
+		for _, k := range t.Keywords {
+			if !bytes.Contains(text, []byte(k)) {
+				missing = append(missing, k)
+			}
+		}
+		if t.KeywordMode == "any" && len(missing) == len(t.Keywords) {
+			return fail(fmt.Sprintf("none of keywords '%s' found", strings.Join(missing, "', '")))
+		} else if t.KeywordMode != "any" && len(missing) > 0 {
+			return fail(fmt.Sprintf("keywords '%s' not found", strings.Join(missing, "', '")))
+		}
+	}
+	if t.AntiKeyword != "" && bytes.Contains(text, []byte(t.AntiKeyword)) {
+		return fail(fmt.Sprintf("anti-keyword '%s' found", t.AntiKeyword))
+	}
+	if t.ExpectHash != "" || t.LearnHash || t.WatchChanges {
+		status.BodyHash = bodyHash(body, t.NormalizeBody)
+	}
+	if t.ExpectHash != "" || t.LearnHash {
+		expect := t.ExpectHash
+		if expect == "" {
+			expect = status.learnedHash
+		}
+		if expect == "" {
+			status.learnedHash = status.BodyHash
+			tlog.Info("learned body hash", "event", "hash_learned", "hash", status.learnedHash)
+		} else if !strings.EqualFold(status.BodyHash, expect) {
+			tlog.Debug("body hash mismatch", "event", "check_failed", "hash", status.BodyHash, "expected", expect)
+			return fail("content changed")
+		}
+	}
+	if truncated && status.ErrorMsg == "" {
+		status.ErrorMsg = fmt.Sprintf("body truncated to %d bytes", maxBody)
+	}
+	return false
+}
+
+// Proxy for checks without Target.Proxy, from HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY. net/http reads them once, tests stand in for it.
+var proxyFromEnvironment = http.ProxyFromEnvironment
+
+// Transport for the target's checks, fresh connections unless
+// ReuseConnections is set.
+func httpTransport(t *Target) (*http.Transport, error) {
+	transport := &http.Transport{
+		DisableKeepAlives:  t.ReuseConnections == nil || !*t.ReuseConnections,
+		DisableCompression: true,
+		Proxy:              proxyFromEnvironment,
+		DialContext:        t.dialer().DialContext,
+	}
+	if t.Proxy != "" {
+		proxyURL, err := url.Parse(t.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy address could not be read, %s", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}
+	if t.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate could not be loaded, %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if t.Host != "" {
+		// Set hostname for TLS connection. This allows us to connect using
+		// another hostname or IP for the actual TCP connection. Handy for GeoDNS scenarios.
+		tlsConfig.ServerName = t.Host
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// Whether code is one of expect, or any 2xx/3xx when expect is empty.
+func expectedStatus(expect []int, code int) bool {
+	if len(expect) == 0 {
+		return code >= 200 && code < 400
+	}
+	for _, c := range expect {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Stable category of a network error, see TargetStatus.FailureClass.
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &alertErr):
+		return "tls"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection_refused"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	}
+	return "other"
+}
+
+// Whether the request failed connecting to the proxy rather than the target.
+func isProxyError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+		return true
+	}
+	// socks5 proxies
+	return strings.Contains(err.Error(), "socks connect")
+}
+
+// The body as UTF-8, decoded from Target.Charset or the charset of the
+// Content-Type. Bodies without a charset, in UTF-8 or failing to decode
+// are returned as is.
+func decodeBody(t *Target, contentType string, body []byte) []byte {
+	name := t.Charset
+	if name == "" {
+		if _, params, err := mime.ParseMediaType(contentType); err == nil {
+			name = params["charset"]
+		}
+	}
+	if name == "" {
+		return body
+	}
+	enc, canonical := charset.Lookup(name)
+	if enc == nil {
+		targetLog(t).Debug("unknown charset, body matched as is", "event", "check", "charset", name)
+		return body
+	} else if canonical == "utf-8" {
+		return body
+	}
+	text, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		targetLog(t).Debug("body could not be decoded, matched as is", "event", "check", "charset", name, "error", err)
+		return body
+	}
+	return text
+}
@@ -0,0 +1,166 @@
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// Check t against url once, with a fresh deadline.
+func checkOnce(t *Target, addr string, status *TargetStatus) bool {
+	addrURL, _ := url.Parse(addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	status.Target = t
+	status.ErrorMsg = ""
+	return checkHTTP(ctx, t, addrURL, status, MaxBodyBytes, 0)
+}
+
+func TestExpectHashCase(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	hash := bodyHash([]byte("hello"), false)
+	for _, expect := range []string{hash, strings.ToUpper(hash)} {
+		target := &Target{Name: "hash", ExpectHash: expect}
+		var status TargetStatus
+		if checkOnce(target, srv.URL, &status) {
+			t.Errorf("ExpectHash %s: %s", expect, status.ErrorMsg)
+		}
+	}
+}
+
+func TestLearnHash(t *testing.T) {
+	var body atomic.Value
+	body.Store("first")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body.Load().(string)))
+	}))
+	defer srv.Close()
+
+	target := &Target{Name: "learn", LearnHash: true}
+	var status TargetStatus
+	for i := 0; i < 2; i++ {
+		if checkOnce(target, srv.URL, &status) {
+			t.Fatalf("check %d: %s", i+1, status.ErrorMsg)
+		}
+	}
+	if target.ExpectHash != "" {
+		t.Error("learned hash written to the shared Target")
+	}
+	body.Store("second")
+	if !checkOnce(target, srv.URL, &status) {
+		t.Error("changed body not noticed")
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	captureLog(t)
+	agents := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents <- r.Header.Get("User-Agent")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		global, target, want string
+	}{
+		{"", "", "pingo2/" + Version},
+		{"monitor/1.0", "", "monitor/1.0"},
+		{"monitor/1.0", "probe/2.0", "probe/2.0"},
+	}
+	for _, tt := range tests {
+		c := NewChecker(Config{Timeout: 5, UserAgent: tt.global})
+		status, err := c.CheckOnce(context.Background(), Target{Name: "ua", Addr: srv.URL, UserAgent: tt.target})
+		if err != nil || !status.Online {
+			t.Fatalf("check failed: %v %s", err, status.ErrorMsg)
+		}
+		if got := <-agents; got != tt.want {
+			t.Errorf("global %q, target %q: sent User-Agent %q, want %q", tt.global, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestKeywordCharset(t *testing.T) {
+	body, err := japanese.ShiftJIS.NewEncoder().String("ステータス: 正常")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contentType atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType.Load().(string))
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		contentType, charset string
+		found                bool
+	}{
+		{"text/plain; charset=Shift_JIS", "", true},
+		{"text/plain", "shift_jis", true},
+		// Charset wins over a wrong Content-Type
+		{"text/plain; charset=iso-8859-1", "sjis", true},
+		{"text/plain", "", false},
+	}
+	for _, tt := range tests {
+		contentType.Store(tt.contentType)
+		target := &Target{Name: "sjis", Keyword: "正常", Charset: tt.charset}
+		var status TargetStatus
+		if failed := checkOnce(target, srv.URL, &status); failed == tt.found {
+			t.Errorf("Content-Type %q, Charset %q: keyword found %v, want %v (%s)", tt.contentType, tt.charset, !failed, tt.found, status.ErrorMsg)
+		}
+	}
+}
+
+// Read the proxy variables on every request, for the rest of the test.
+func proxyEnv(t *testing.T, httpProxy, noProxy string) {
+	t.Setenv("HTTP_PROXY", httpProxy)
+	t.Setenv("NO_PROXY", noProxy)
+	t.Cleanup(func() { proxyFromEnvironment = http.ProxyFromEnvironment })
+	proxyFromEnvironment = func(req *http.Request) (*url.URL, error) {
+		return httpproxy.FromEnvironment().ProxyFunc()(req.URL)
+	}
+}
+
+func TestProxyFromEnvironment(t *testing.T) {
+	proxied := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a proxy gets the absolute URL
+		proxied <- r.URL.String()
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	// the name doesn't resolve, only the proxy can reach it
+	const addr = "http://backend.pingo2.test/health"
+	proxyEnv(t, proxy.URL, "")
+	target := &Target{Name: "proxied", Keyword: "via proxy"}
+	var status TargetStatus
+	if checkOnce(target, addr, &status) {
+		t.Fatalf("check through HTTP_PROXY failed: %s", status.ErrorMsg)
+	}
+	if got := <-proxied; got != addr {
+		t.Errorf("proxy got %s, want %s", got, addr)
+	}
+
+	proxyEnv(t, proxy.URL, "backend.pingo2.test")
+	if !checkOnce(target, addr, &status) {
+		t.Error("NO_PROXY host went through the proxy")
+	}
+	select {
+	case got := <-proxied:
+		t.Errorf("NO_PROXY request reached the proxy: %s", got)
+	default:
+	}
+}
@@ -0,0 +1,178 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+//	"golang.org/x/net/internal/iana"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	TimeSliceLength  = 8
+	ProtocolICMP     = 1
+	ProtocolIPv6ICMP = 58
+)
+
+const ICMPReadTimeout = 2
+const ICMPWriteTimeout = 2
+
+// Outcome of a Ping run.
+type PingStats struct {
+	Sent     int
+	Received int
+	// average round trip of the replies
+	RTT time.Duration
+}
+
+// Percentage of echo requests that got no reply.
+func (p PingStats) Loss() float64 {
+	if p.Sent == 0 {
+		return 100
+	}
+	return 100 * float64(p.Sent-p.Received) / float64(p.Sent)
+}
+
+// non-privileged ping on Linux requires special sysctl setting:
+//     sysctl -w net.ipv4.ping_group_range="0 0"
+//
+// Where group matches running process
+// See: http://stackoverflow.com/questions/8290046/icmp-sockets-linux/20105379#20105379
+//
+// hostname is resolved through r, the system resolver when nil. network is
+// "ip4" or "ip6" to force an address family, or "ip" to use the first
+// address, IPv4 if there is one. count echo requests are sent one
+// after the other, with size bytes of payload when size > 0. Requests not
+// answered by the ctx deadline count as lost.
+func Ping(ctx context.Context, r *net.Resolver, hostname string, network string, count int, size int) (stats PingStats, err error) {
+	ipAddr, err := resolveIPAddr(ctx, r, network, hostname)
+	if err != nil {
+		if _, ok := err.(*net.AddrError); ok && network == "ip6" {
+			return stats, fmt.Errorf("%s has no IPv6 address", hostname)
+		} else if ok && network == "ip4" {
+			return stats, fmt.Errorf("%s has no IPv4 address", hostname)
+		}
+		return stats, err
+	}
+
+	listen, proto := "udp4", ProtocolICMP
+	var echo, echoReply icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	laddr := "0.0.0.0"
+	if ipAddr.IP.To4() == nil {
+		listen, proto = "udp6", ProtocolIPv6ICMP
+		echo, echoReply = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+		laddr = "::"
+	}
+
+	c, err := icmp.ListenPacket(listen, laddr)
+	if err != nil {
+		return stats, err
+	}
+	defer c.Close()
+
+	data := []byte("HELLO-R-U-THERE")
+	if size > 0 {
+		data = bytes.Repeat(data, size/len(data)+1)[:size]
+	}
+	if count <= 0 {
+		count = 1
+	}
+
+	var total time.Duration
+	rb := make([]byte, 1500+size)
+	for seq := 1; seq <= count; seq++ {
+		if ctx.Err() != nil {
+			// out of time, the rest is lost
+			stats.Sent = count
+			break
+		}
+		readDeadline := time.Now().Add(time.Second * ICMPReadTimeout)
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(readDeadline) {
+			readDeadline = deadline
+		}
+		if err = c.SetReadDeadline(readDeadline); err != nil {
+			return stats, err
+		}
+		if err = c.SetWriteDeadline(time.Now().Add(time.Second * ICMPWriteTimeout)); err != nil {
+			return stats, err
+		}
+
+		wm := icmp.Message{
+			Type: echo, Code: 0,
+			Body: &icmp.Echo{
+				ID: os.Getpid() & 0xffff, Seq: seq,
+				Data: data,
+			},
+		}
+		wb, err := wm.Marshal(nil)
+		if err != nil {
+			return stats, err
+		}
+		sent := time.Now()
+		if _, err := c.WriteTo(wb, &net.UDPAddr{IP: ipAddr.IP, Zone: ipAddr.Zone}); err != nil {
+			return stats, err
+		}
+		stats.Sent++
+
+		// skip replies to earlier, late requests
+		for {
+			n, _, err := c.ReadFrom(rb)
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					// lost
+					break
+				}
+				return stats, err
+			}
+			rm, err := icmp.ParseMessage(proto, rb[:n])
+			if err != nil {
+				return stats, err
+			}
+			// the kernel picks the ID of unprivileged echo requests, only
+			// the sequence number can be matched
+			if body, ok := rm.Body.(*icmp.Echo); ok && rm.Type == echoReply && body.Seq == seq {
+				stats.Received++
+				total += time.Since(sent)
+				break
+			}
+		}
+	}
+	if stats.Received > 0 {
+		stats.RTT = total / time.Duration(stats.Received)
+	}
+	return stats, nil
+}
+
+// Percentage of lost echo requests tolerated for t.
+func (t *Target) pingMaxLoss() float64 {
+	if t.PingMaxLoss == nil {
+		return PingMaxLoss
+	}
+	return *t.PingMaxLoss
+}
+
+// Address of hostname for network "ip", "ip4" or "ip6", preferring IPv4
+// like net.ResolveIPAddr.
+func resolveIPAddr(ctx context.Context, r *net.Resolver, network, hostname string) (*net.IPAddr, error) {
+	if _, err := netip.ParseAddr(hostname); err == nil {
+		// a literal, keeping its zone
+		return net.ResolveIPAddr(network, hostname)
+	}
+	ips, err := r.LookupIP(ctx, network, hostname)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return &net.IPAddr{IP: ip}, nil
+		}
+	}
+	return &net.IPAddr{IP: ips[0]}, nil
+}
@@ -0,0 +1,54 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPing6Loopback(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stats, err := Ping(ctx, nil, "::1", "ip6", 2, 64)
+	if errors.Is(err, os.ErrPermission) || errors.Is(err, syscall.EPROTONOSUPPORT) || errors.Is(err, syscall.EAFNOSUPPORT) {
+		t.Skipf("no ICMPv6 sockets here: %s", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sent != 2 || stats.Received != 2 || stats.Loss() != 0 {
+		t.Errorf("got %+v from ::1", stats)
+	}
+}
+
+func TestPingAddressFamily(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := Ping(ctx, nil, "127.0.0.1", "ip6", 1, 0)
+	var addrErr *net.AddrError
+	if err == nil || errors.As(err, &addrErr) || !strings.Contains(err.Error(), "no IPv6 address") {
+		t.Errorf("got %v pinging an IPv4 literal over ip6", err)
+	}
+}
+
+func TestPingMaxLoss(t *testing.T) {
+	none, some := 0.0, 40.0
+	tests := []struct {
+		maxLoss *float64
+		want    float64
+	}{
+		{nil, PingMaxLoss},
+		// zero tolerance, not the default
+		{&none, 0},
+		{&some, 40},
+	}
+	for _, tt := range tests {
+		if got := (&Target{PingMaxLoss: tt.maxLoss}).pingMaxLoss(); got != tt.want {
+			t.Errorf("PingMaxLoss %v: tolerated %v, want %v", tt.maxLoss, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package monitor
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Forces the debug log level over Config.LogLevel, the -d flag
+var Debug = false
+
+// Set up the default logger from Config.LogLevel and Config.LogFormat.
+func SetupLogging(config Config) error {
+	level := slog.LevelInfo
+	if config.LogLevel != "" {
+		if err := level.UnmarshalText([]byte(config.LogLevel)); err != nil {
+			return fmt.Errorf("unknown LogLevel '%s'", config.LogLevel)
+		}
+	}
+	if Debug {
+		level = slog.LevelDebug
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(config.LogFormat) {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown LogFormat '%s', want text or json", config.LogFormat)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// Logger tagging every record with the target.
+func targetLog(t *Target) *slog.Logger {
+	return slog.With("target_id", t.Id, "addr", t.Addr)
+}
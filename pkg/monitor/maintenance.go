@@ -0,0 +1,109 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A recurring maintenance window, during which alerts are suppressed.
+type Window struct {
+	// Days of the week, e.g. ["Sat", "Sun"]. Every day when empty
+	Days []string
+	// Start and end time of day in local time, "15:04". A window ending
+	// before it starts runs past midnight
+	Start string
+	End   string
+	// Time zone of Start and End, e.g. "Europe/Paris". Local time when
+	// empty
+	Timezone string
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func parseWeekday(day string) (time.Weekday, error) {
+	if len(day) >= 3 {
+		if d, ok := weekdays[strings.ToLower(day[:3])]; ok {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown day '%s'", day)
+}
+
+// minutes since midnight of a "15:04" time
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s', want HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w Window) validate() error {
+	for _, day := range w.Days {
+		if _, err := parseWeekday(day); err != nil {
+			return err
+		}
+	}
+	if _, err := parseClock(w.Start); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return fmt.Errorf("unknown time zone '%s'", w.Timezone)
+	}
+	_, err := parseClock(w.End)
+	return err
+}
+
+func (w Window) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if wd, err := parseWeekday(d); err == nil && wd == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the window is open at now.
+func (w Window) active(now time.Time) bool {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+	if w.Timezone != "" {
+		loc, err := time.LoadLocation(w.Timezone)
+		if err != nil {
+			return false
+		}
+		now = now.In(loc)
+	}
+	cur := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return cur >= start && cur < end && w.onDay(now.Weekday())
+	}
+	// past midnight, the part after midnight belongs to the previous day
+	return (cur >= start && w.onDay(now.Weekday())) ||
+		(cur < end && w.onDay(now.AddDate(0, 0, -1).Weekday()))
+}
+
+// Whether any of the windows is open at now.
+func inMaintenance(now time.Time, windows ...[]Window) bool {
+	for _, ws := range windows {
+		for _, w := range ws {
+			if w.active(now) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package monitor
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// default path of the Prometheus endpoint
+const MetricsPath = "/metrics"
+
+// Alerts sent, per target id
+var alertsSent = struct {
+	sync.Mutex
+	count map[int]int
+}{count: make(map[int]int)}
+
+func countAlert(t *Target) {
+	alertsSent.Lock()
+	alertsSent.count[t.Id]++
+	alertsSent.Unlock()
+	if c := statsd.Load(); c != nil {
+		c.alert(t)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// characters not allowed in Prometheus label names
+var labelName = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Label name of a tag key. The tag_ prefix keeps tags apart from the
+// labels set here and from the names Prometheus reserves.
+func tagLabel(key string) string {
+	return "tag_" + labelName.ReplaceAllString(key, "_")
+}
+
+func targetLabels(t *Target) string {
+	labels := fmt.Sprintf(`id="%d",name="%s",addr="%s"`, t.Id, labelEscaper.Replace(t.Name), labelEscaper.Replace(t.Addr))
+	// tags, sorted so every scrape has the same order
+	keys := make([]string, 0, len(t.Tags))
+	for k := range t.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		// keys Validate rejects as clashing, the first one is kept
+		name := tagLabel(k)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		labels += fmt.Sprintf(`,%s="%s"`, name, labelEscaper.Replace(t.Tags[k]))
+	}
+	return labels
+}
+
+// Write the latest target statuses in the Prometheus text format.
+func metricsHandler(state *State) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses := state.Snapshot()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		fmt.Fprintln(w, "# HELP pingo_target_up Whether the target is up (1) or down (0).")
+		fmt.Fprintln(w, "# TYPE pingo_target_up gauge")
+		for _, s := range statuses {
+			up := 0
+			if s.Online {
+				up = 1
+			}
+			fmt.Fprintf(w, "pingo_target_up{%s} %d\n", targetLabels(s.Target), up)
+		}
+
+		fmt.Fprintln(w, "# HELP pingo_target_state Whether the target is in the state: up, warn (up but slow), down or error (misconfigured).")
+		fmt.Fprintln(w, "# TYPE pingo_target_state gauge")
+		for _, s := range statuses {
+			for _, state := range []string{"up", "warn", "down", "error"} {
+				v := 0
+				if s.State == state {
+					v = 1
+				}
+				fmt.Fprintf(w, "pingo_target_state{%s,state=\"%s\"} %d\n", targetLabels(s.Target), state, v)
+			}
+		}
+
+		fmt.Fprintln(w, "# HELP pingo_target_maintenance Whether the target is in a maintenance window.")
+		fmt.Fprintln(w, "# TYPE pingo_target_maintenance gauge")
+		for _, s := range statuses {
+			m := 0
+			if s.Maintenance {
+				m = 1
+			}
+			fmt.Fprintf(w, "pingo_target_maintenance{%s} %d\n", targetLabels(s.Target), m)
+		}
+
+		fmt.Fprintln(w, "# HELP pingo_target_latency_seconds Duration of the last check.")
+		fmt.Fprintln(w, "# TYPE pingo_target_latency_seconds gauge")
+		for _, s := range statuses {
+			fmt.Fprintf(w, "pingo_target_latency_seconds{%s} %g\n", targetLabels(s.Target), s.Latency.Seconds())
+		}
+
+		fmt.Fprintln(w, "# HELP pingo_target_uptime_percent Percentage of successful checks over a window.")
+		fmt.Fprintln(w, "# TYPE pingo_target_uptime_percent gauge")
+		for _, s := range statuses {
+			windows := make([]string, 0, len(s.Uptime))
+			for window := range s.Uptime {
+				windows = append(windows, window)
+			}
+			sort.Strings(windows)
+			for _, window := range windows {
+				fmt.Fprintf(w, "pingo_target_uptime_percent{%s,window=\"%s\"} %g\n", targetLabels(s.Target), window, s.Uptime[window])
+			}
+		}
+
+		groups := serviceSnapshot()
+		fmt.Fprintln(w, "# HELP pingo_service_up Whether a quorum of the service's targets is up (1) or not (0).")
+		fmt.Fprintln(w, "# TYPE pingo_service_up gauge")
+		for _, s := range groups {
+			up := 0
+			if s.Online {
+				up = 1
+			}
+			fmt.Fprintf(w, "pingo_service_up{service=\"%s\"} %d\n", labelEscaper.Replace(s.Name), up)
+		}
+
+		fmt.Fprintln(w, "# HELP pingo_service_members_up Member targets of the service that are up.")
+		fmt.Fprintln(w, "# TYPE pingo_service_members_up gauge")
+		for _, s := range groups {
+			fmt.Fprintf(w, "pingo_service_members_up{service=\"%s\"} %d\n", labelEscaper.Replace(s.Name), s.Up)
+		}
+
+		alertsSent.Lock()
+		defer alertsSent.Unlock()
+		fmt.Fprintln(w, "# HELP pingo_alerts_total Alerts sent for the target.")
+		fmt.Fprintln(w, "# TYPE pingo_alerts_total counter")
+		for _, s := range statuses {
+			fmt.Fprintf(w, "pingo_alerts_total{%s} %d\n", targetLabels(s.Target), alertsSent.count[s.Target.Id])
+		}
+	}
+}
+
+// Add metrics to status, the status page server's mux, or return a server
+// of their own when config.MetricsListen is set.
+func metricsServer(config Config, state *State, status *http.ServeMux) *http.Server {
+	path := config.MetricsPath
+	if path == "" {
+		path = MetricsPath
+	}
+	if config.MetricsListen == "" {
+		status.HandleFunc(path, metricsHandler(state))
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, metricsHandler(state))
+	slog.Info("metrics available", "url", "http://"+config.MetricsListen+path)
+	return &http.Server{Addr: config.MetricsListen, Handler: mux}
+}
@@ -0,0 +1,44 @@
+package monitor
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsTagLabels(t *testing.T) {
+	state := NewState()
+	target := &Target{Id: 1, Name: "web", Addr: "http://web", Tags: map[string]string{
+		"state": "x", "window": "y", "id": "z", "__name__": "reserved", "team-a": "a", "team_a": "b",
+	}}
+	state.Update(TargetStatus{Target: target, Online: true, State: "up", LastCheck: time.Now()})
+
+	rec := httptest.NewRecorder()
+	metricsHandler(state)(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		open, end := strings.Index(line, "{"), strings.LastIndex(line, "}")
+		if strings.HasPrefix(line, "#") || open < 0 {
+			continue
+		}
+		// the test values hold no commas
+		seen := map[string]bool{}
+		for _, label := range strings.Split(line[open+1:end], ",") {
+			name := label[:strings.Index(label, "=")]
+			if seen[name] {
+				t.Errorf("label %s repeated in %s", name, line)
+			} else if strings.HasPrefix(name, "__") {
+				t.Errorf("reserved label %s in %s", name, line)
+			}
+			seen[name] = true
+		}
+	}
+	if !strings.Contains(rec.Body.String(), `pingo_target_up{id="1",name="web",addr="http://web",tag___name__="reserved",tag_id="z",tag_state="x",tag_team_a="a",tag_window="y"} 1`) {
+		t.Errorf("tags not labelled as expected:\n%s", rec.Body.String())
+	}
+
+	config := Config{Timeout: 5, Targets: []Target{*target}}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "Tags 'team-a' and 'team_a' are both the metrics label tag_team_a") {
+		t.Errorf("clashing tag keys not rejected: %v", err)
+	}
+}
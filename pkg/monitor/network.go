@@ -0,0 +1,60 @@
+package monitor
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Set while the Config.NetworkCheck canary is failing. Down alerts for
+// regular targets are suppressed meanwhile.
+var networkDown atomic.Bool
+
+// Check the monitor's own network through Config.NetworkCheck, alerting
+// once when it goes down and once when it's back. A check restarted by a
+// reload carries on from the current state.
+func startNetworkCheck(ctx context.Context, wg *sync.WaitGroup, config Config) {
+	if config.NetworkCheck == "" {
+		networkDown.Store(false)
+		return
+	}
+	t := Target{Name: "monitor network", Addr: config.NetworkCheck, canary: true, resolver: configResolver(config)}
+	addrURL, err := url.Parse(t.Addr)
+	if err != nil {
+		targetLog(&t).Error("network check address could not be read", "event", "config_error", "error", err)
+		return
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tlog := targetLog(&t)
+		ticker := time.NewTicker(CheckInterval * time.Second)
+		defer ticker.Stop()
+		status := TargetStatus{Target: &t, Online: !networkDown.Load(), Since: time.Now()}
+		for {
+			status.ErrorMsg = ""
+			failed := probe(&t, addrURL, &status, config)
+			status.LastCheck = time.Now()
+			if failed == status.Online {
+				status.Online = !failed
+				status.Since = status.LastCheck
+				networkDown.Store(failed)
+				if failed {
+					tlog.Error("monitor network down, suppressing target down alerts", "event", "down", "error", status.ErrorMsg)
+				} else {
+					tlog.Info("monitor network back up", "event", "up")
+				}
+				alert(&status, config)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
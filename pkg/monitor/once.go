@@ -0,0 +1,53 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Check every target once without alerting and print the outcome, see
+// Config.Once. Reports whether all targets are up.
+func RunOnce(config Config) bool {
+	limitConcurrency(config)
+	config.Once = true
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	res := make(chan TargetStatus)
+	expected := 0
+	for _, target := range config.Targets {
+		if target.Addr != "" {
+			startTarget(ctx, &wg, target, res, config)
+			expected++
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(res)
+	}()
+
+	var statuses []TargetStatus
+	for status := range res {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Target.Id < statuses[j].Target.Id })
+
+	up := 0
+	for _, status := range statuses {
+		if status.Online {
+			up++
+			fmt.Printf("PASS  %s (%s) %s\n", status.Target.Name, status.Target.Addr, status.Latency)
+		} else {
+			fmt.Printf("FAIL  %s (%s): %s\n", status.Target.Name, status.Target.Addr, status.ErrorMsg)
+		}
+	}
+	// targets with config errors never report
+	if skipped := expected - len(statuses); skipped > 0 {
+		fmt.Printf("ERROR %d targets could not be checked, see the log\n", skipped)
+	}
+	fmt.Printf("%d of %d targets up\n", up, expected)
+	return up == expected
+}
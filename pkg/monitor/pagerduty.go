@@ -0,0 +1,45 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const pagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary  string `json:"summary"`
+	Source   string `json:"source"`
+	Severity string `json:"severity"`
+}
+
+// Trigger a PagerDuty incident for a down target, or resolve it once the
+// target is back up. Both use the same dedup key, derived from the target id.
+func PagerDutyAlert(status TargetStatus, config Config) error {
+	event := pagerDutyEvent{
+		RoutingKey:  config.Alert.PagerDutyKey,
+		EventAction: "resolve",
+		DedupKey:    fmt.Sprintf("pingo2-%d", status.Target.Id),
+	}
+	if !status.Online {
+		event.EventAction = "trigger"
+		event.Payload = &pagerDutyPayload{
+			Summary:  fmt.Sprintf("%s is DOWN: %s", status.Target.Name, status.ErrorMsg),
+			Source:   status.Target.Addr,
+			Severity: "critical",
+		}
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return postAlert("PagerDuty", pagerDutyURL, "application/json", nil, body, config)
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package monitor
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// Run cmd in its own process group and kill the whole group when its
+// context is done.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// don't wait forever on output pipes held open by stray children
+	cmd.WaitDelay = time.Second
+}
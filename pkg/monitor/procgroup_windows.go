@@ -0,0 +1,13 @@
+//go:build windows
+
+package monitor
+
+import (
+	"os/exec"
+	"time"
+)
+
+// No process groups here, only the command itself is killed.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = time.Second
+}
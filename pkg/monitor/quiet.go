@@ -0,0 +1,83 @@
+package monitor
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// how often the end of quiet hours is looked for, in seconds
+const QuietHoursCheck = 30
+
+// Alerts of targets with RespectQuietHours held during Config.QuietHours,
+// the latest one per target id.
+var quiet = struct {
+	sync.Mutex
+	held map[int]TargetStatus
+}{held: make(map[int]TargetStatus)}
+
+// Whether the alert is held until quiet hours end.
+func quietHold(status TargetStatus, config Config) bool {
+	if !status.Target.RespectQuietHours || config.QuietHours == nil || !config.QuietHours.active(time.Now()) {
+		return false
+	}
+	quiet.Lock()
+	quiet.held[status.Target.Id] = status
+	quiet.Unlock()
+	return true
+}
+
+// Send held alerts as one summary once quiet hours are over, or right
+// away when a reload removed them.
+func startQuietHours(ctx context.Context, wg *sync.WaitGroup, config Config) {
+	if config.QuietHours == nil {
+		releaseQuiet(config)
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(QuietHoursCheck * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			if !config.QuietHours.active(time.Now()) {
+				releaseQuiet(config)
+			}
+		}
+	}()
+}
+
+// Forget the held alerts. Returns the names of their targets, sorted.
+func dropQuiet() []string {
+	quiet.Lock()
+	defer quiet.Unlock()
+	var names []string
+	for _, status := range quiet.held {
+		names = append(names, status.Target.Name)
+	}
+	quiet.held = make(map[int]TargetStatus)
+	sort.Strings(names)
+	return names
+}
+
+// Send the held alerts, if any, as one summary.
+func releaseQuiet(config Config) {
+	quiet.Lock()
+	var held []TargetStatus
+	for _, status := range quiet.held {
+		held = append(held, status)
+	}
+	quiet.held = make(map[int]TargetStatus)
+	quiet.Unlock()
+	if len(held) > 0 {
+		slog.Info("quiet hours over", "event", "quiet_end", "alerts", len(held))
+		sendDigest("quiet hours summary", held, config)
+	}
+}
@@ -0,0 +1,296 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Target checks currently running, so the config can be reloaded without
+// restarting pingo2.
+type runner struct {
+	ctx    context.Context
+	wg     *sync.WaitGroup
+	res    chan TargetStatus
+	config Config
+	// the config started with, for settings only read at startup
+	initial Config
+	// running targets by targetKey
+	targets map[string]*running
+	// id given to the next added target
+	nextId int
+	// stops the routines started by startBackground
+	stopBackground context.CancelFunc
+}
+
+type running struct {
+	target Target
+	cancel context.CancelFunc
+}
+
+// What a reload changed, answered by POST /reload.
+type reloadDiff struct {
+	Added, Removed, Changed []reloadedTarget
+	GlobalChanged           bool
+	// settings changed since startup which a reload doesn't apply
+	RequiresRestart []string `json:",omitempty"`
+}
+
+// Config fields only read at startup, see reloadDiff.RequiresRestart.
+var startupSettings = []string{"Listen", "MetricsListen", "MetricsPath", "Database", "HistoryFile", "UptimeWindows", "MaxConcurrency"}
+
+type reloadedTarget struct {
+	Id   int
+	Name string
+}
+
+// A file that could not be read as a config or failed Validate, as opposed
+// to one that could not be opened.
+var errInvalidConfig = errors.New("invalid config")
+
+// POST /reload: re-read the config in the serve loop, see reloadHandler.
+type reloadResult struct {
+	diff reloadDiff
+	err  error
+}
+
+func newRunner(ctx context.Context, wg *sync.WaitGroup, res chan TargetStatus, config Config) *runner {
+	r := &runner{ctx: ctx, wg: wg, res: res, config: config, initial: config, targets: make(map[string]*running), nextId: 1}
+	// before any check, alerts are counted too
+	r.startBackground()
+	for i, key := range targetKeys(config.Targets) {
+		if t := config.Targets[i]; t.Addr != "" {
+			r.start(key, t)
+		}
+	}
+	return r
+}
+
+// Keys matching targets across reloads, by name and address. Repeated
+// ones are told apart by their position among themselves.
+func targetKeys(targets []Target) []string {
+	keys := make([]string, len(targets))
+	seen := make(map[string]int)
+	for i, t := range targets {
+		key := t.Name + "\x00" + t.Addr
+		seen[key]++
+		keys[i] = fmt.Sprintf("%s\x00%d", key, seen[key])
+	}
+	return keys
+}
+
+// Ids of targets by key, see targetKeys.
+func targetIds(targets []Target) map[string]int {
+	ids := make(map[string]int, len(targets))
+	for i, key := range targetKeys(targets) {
+		ids[key] = targets[i].Id
+	}
+	return ids
+}
+
+// Ids of the running targets by key.
+func (r *runner) keyIds() map[string]int {
+	ids := make(map[string]int, len(r.targets))
+	for key, run := range r.targets {
+		ids[key] = run.target.Id
+	}
+	return ids
+}
+
+func (r *runner) start(key string, t Target) {
+	if t.Id >= r.nextId {
+		r.nextId = t.Id + 1
+	}
+	ctx, cancel := context.WithCancel(r.ctx)
+	stored := t
+	stored.restored = nil
+	r.targets[key] = &running{target: stored, cancel: cancel}
+	startTarget(ctx, r.wg, t, r.res, r.config)
+}
+
+// Start what runs alongside the targets: StatsD, the network check, quiet
+// hours and the heartbeat. A reload changing the global settings restarts
+// them with the new config.
+func (r *runner) startBackground() {
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.stopBackground = cancel
+	startStatsd(r.config)
+	startNetworkCheck(ctx, r.wg, r.config)
+	startQuietHours(ctx, r.wg, r.config)
+	startHeartbeat(ctx, r.wg, r.config)
+}
+
+// Ids of the running targets.
+func (r *runner) ids() map[int]bool {
+	ids := make(map[int]bool)
+	for _, run := range r.targets {
+		ids[run.target.Id] = true
+	}
+	return ids
+}
+
+// Whether a target with this id is still running. Statuses from removed
+// targets may arrive after they were cancelled.
+func (r *runner) active(id int) bool {
+	for _, run := range r.targets {
+		if run.target.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply a new config: start added targets, stop removed ones and restart
+// changed ones, carrying over their current status.
+func (r *runner) reload(config Config, state *State) reloadDiff {
+	// a change outside the targets applies to all of them
+	oldGlobal, newGlobal := r.config, config
+	oldGlobal.Targets, newGlobal.Targets = nil, nil
+	// services only read statuses, the checks needn't restart
+	oldGlobal.Services, newGlobal.Services = nil, nil
+	globalChanged := !reflect.DeepEqual(oldGlobal, newGlobal)
+	r.config = config
+
+	diff := reloadDiff{GlobalChanged: globalChanged}
+	for _, name := range startupSettings {
+		was, now := reflect.ValueOf(r.initial).FieldByName(name), reflect.ValueOf(config).FieldByName(name)
+		if !reflect.DeepEqual(was.Interface(), now.Interface()) {
+			diff.RequiresRestart = append(diff.RequiresRestart, name)
+		}
+	}
+	state.configure(config)
+	if globalChanged {
+		r.stopBackground()
+		r.startBackground()
+	}
+	keys := targetKeys(config.Targets)
+	wanted := make(map[string]bool)
+	for _, key := range keys {
+		wanted[key] = true
+	}
+	for key, run := range r.targets {
+		if !wanted[key] {
+			run.cancel()
+			delete(r.targets, key)
+			state.remove(run.target.Id)
+			setDown(run.target.Id, false)
+			targetLog(&run.target).Info("target removed", "event", "reload")
+			diff.Removed = append(diff.Removed, reloadedTarget{run.target.Id, run.target.Name})
+		}
+	}
+
+	// running targets keep their id, the config ones are by position
+	ids := make(map[int]int)
+	next := r.nextId
+	for i, key := range keys {
+		if run, ok := r.targets[key]; ok {
+			ids[config.Targets[i].Id] = run.target.Id
+		} else {
+			ids[config.Targets[i].Id] = next
+			next++
+		}
+	}
+	// service members are config ids too
+	r.config.Services = make([]ServiceGroup, len(config.Services))
+	for i, s := range config.Services {
+		s.Targets = append([]int(nil), s.Targets...)
+		for j, id := range s.Targets {
+			s.Targets[j] = ids[id]
+		}
+		r.config.Services[i] = s
+	}
+	for i, key := range keys {
+		t := config.Targets[i]
+		if t.Addr == "" {
+			continue
+		}
+		t.Id = ids[t.Id]
+		if len(t.DependsOn) > 0 {
+			deps := make([]int, len(t.DependsOn))
+			for j, dep := range t.DependsOn {
+				deps[j] = ids[dep]
+			}
+			t.DependsOn = deps
+		}
+		run, ok := r.targets[key]
+		if !ok {
+			r.start(key, t)
+			diff.Added = append(diff.Added, reloadedTarget{t.Id, t.Name})
+			continue
+		}
+		if !globalChanged && reflect.DeepEqual(t, run.target) {
+			continue
+		}
+		run.cancel()
+		if status, ok := state.Get(t.Id); ok {
+			t.restored = &status
+		}
+		r.start(key, t)
+		diff.Changed = append(diff.Changed, reloadedTarget{t.Id, t.Name})
+	}
+	slog.Info("config reloaded", "event", "reload", "added", len(diff.Added), "removed", len(diff.Removed), "changed", len(diff.Changed), "global_changed", globalChanged)
+	if len(diff.RequiresRestart) > 0 {
+		slog.Warn("settings changed that only apply after a restart", "event", "reload", "settings", strings.Join(diff.RequiresRestart, ", "))
+	}
+	return diff
+}
+
+// Re-read the config file after SIGHUP or POST /reload. An unreadable or
+// invalid file is logged and the running config kept.
+func reloadConfig(filename string, r *runner, state *State) (reloadDiff, error) {
+	config, err := ReadConfig(filename)
+	if err != nil {
+		slog.Error("config could not be reloaded", "event", "reload", "file", filename, "error", err)
+		return reloadDiff{}, err
+	}
+	if err := config.Validate(); err != nil {
+		slog.Error("config not reloaded, invalid", "event", "reload", "file", filename, "error", err)
+		return reloadDiff{}, fmt.Errorf("%w:\n%s", errInvalidConfig, err)
+	}
+	if err := SetupLogging(config); err != nil {
+		slog.Error("config not reloaded, invalid", "event", "reload", "file", filename, "error", err)
+		return reloadDiff{}, fmt.Errorf("%w, %s", errInvalidConfig, err)
+	}
+	if err := openEventLog(config); err != nil {
+		slog.Error("event log could not be opened, keeping the previous one", "event", "reload", "file", config.EventLog, "error", err)
+	}
+	return r.reload(config, state), nil
+}
+
+// POST /reload, reloads the config like SIGHUP and answers with the targets
+// added, removed and changed. The reload itself runs in the serve loop,
+// which owns the runner.
+func reloadHandler(reloads chan<- chan reloadResult) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		reply := make(chan reloadResult, 1)
+		select {
+		case reloads <- reply:
+		case <-r.Context().Done():
+			return
+		}
+		var result reloadResult
+		select {
+		case result = <-reply:
+		case <-r.Context().Done():
+			return
+		}
+		if errors.Is(result.err, errInvalidConfig) {
+			http.Error(w, result.err.Error(), http.StatusBadRequest)
+			return
+		} else if result.err != nil {
+			http.Error(w, result.err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, result.diff)
+	}
+}
@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func udpListener(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// Settings outside the targets are applied by a reload, not only at start.
+func TestReloadRestartsBackground(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+		statsd.Store(nil)
+	}()
+
+	first, second := udpListener(t), udpListener(t)
+	networkDown.Store(true)
+	r := newRunner(ctx, &wg, make(chan TargetStatus), Config{Statsd: first.LocalAddr().String(), Timeout: 1})
+	if networkDown.Load() {
+		t.Error("network still down without a NetworkCheck")
+	}
+
+	diff := r.reload(Config{Statsd: second.LocalAddr().String(), Timeout: 1}, NewState())
+	if !diff.GlobalChanged {
+		t.Fatal("changed Statsd not reported")
+	}
+	countAlert(&Target{Id: 1, Name: "reloaded"})
+
+	second.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 512)
+	n, _, err := second.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("no metric at the reloaded StatsD address: %s", err)
+	}
+	if line := string(buf[:n]); !strings.HasPrefix(line, "pingo.alerts:1|c|") {
+		t.Errorf("got %q", line)
+	}
+	first.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, _, err := first.ReadFrom(buf); err == nil {
+		t.Error("metric still sent to the previous StatsD address")
+	}
+}
+
+func TestReloadRequiresRestart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	state := NewState()
+	r := newRunner(ctx, &wg, make(chan TargetStatus), Config{Database: "a.db", Timeout: 1})
+	diff := r.reload(Config{Database: "b.db", MaxConcurrency: 4, HistorySize: 7, Timeout: 1}, state)
+	if want := []string{"Database", "MaxConcurrency"}; !reflect.DeepEqual(diff.RequiresRestart, want) {
+		t.Errorf("RequiresRestart %v, want %v", diff.RequiresRestart, want)
+	}
+	if state.HistorySize != 7 {
+		t.Errorf("HistorySize %d not applied", state.HistorySize)
+	}
+
+	// still not applied on the next reload
+	diff = r.reload(Config{Database: "b.db", MaxConcurrency: 4, Timeout: 1}, state)
+	if want := []string{"Database", "MaxConcurrency"}; !reflect.DeepEqual(diff.RequiresRestart, want) {
+		t.Errorf("RequiresRestart %v after a second reload, want %v", diff.RequiresRestart, want)
+	}
+	if state.HistorySize != HistorySize {
+		t.Errorf("HistorySize %d, want the default back", state.HistorySize)
+	}
+}
@@ -0,0 +1,149 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// how often history is written to Config.HistoryFile
+const HistorySaveInterval = 60
+
+// Run pingo2: check the targets of config, read from filename, serve the
+// status page on listen and reload the config on SIGHUP or POST /reload,
+// until SIGINT or SIGTERM. Returns nil once shut down cleanly, or why it
+// stopped early or didn't shut down in time.
+func Serve(filename string, listen string, config Config) error {
+	limitConcurrency(config)
+	pending.open()
+
+	// Running
+	res := make(chan TargetStatus)
+	state := NewState()
+	state.configure(config)
+	if len(config.UptimeWindows) > 0 {
+		state.UptimeWindows = config.UptimeWindows
+	}
+
+	// only read at startup, like the database
+	historyFile := config.HistoryFile
+	var saveHistory <-chan time.Time
+	if historyFile != "" {
+		if err := state.loadHistory(historyFile, targetIds(config.Targets)); err != nil {
+			slog.Error("history file could not be read", "file", historyFile, "error", err)
+		}
+		ticker := time.NewTicker(HistorySaveInterval * time.Second)
+		defer ticker.Stop()
+		saveHistory = ticker.C
+	}
+
+	var db *DB
+	if config.Database != "" {
+		var err error
+		db, err = openDB(config.Database)
+		if err != nil {
+			return fmt.Errorf("database %s could not be opened, %w", config.Database, err)
+		}
+		defer db.Close()
+		last, err := db.last()
+		if err != nil {
+			slog.Error("database could not be read", "file", config.Database, "error", err)
+		}
+		for i, target := range config.Targets {
+			if s, ok := last[target.Id]; ok {
+				config.Targets[i].restored = &s
+			}
+		}
+	}
+
+	if err := openEventLog(config); err != nil {
+		return fmt.Errorf("event log %s could not be opened, %w", config.EventLog, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	targets := newRunner(ctx, &wg, res, config)
+	setServices(ctx, &wg, config.Services, config)
+	// targets not checked yet, for Alert.StartupSummary
+	var unchecked map[int]bool
+	if config.Alert.StartupSummary {
+		unchecked = targets.ids()
+	}
+
+	// HTTP
+	reloads := make(chan chan reloadResult)
+	mux := statusMux(state, reloads)
+	serverErrs := make(chan error, 2)
+	srv := &http.Server{Addr: listen, Handler: mux}
+	defer srv.Close()
+	go func() { serverErrs <- startHttp(srv) }()
+	if metrics := metricsServer(config, state, mux); metrics != nil {
+		defer metrics.Close()
+		go func() { serverErrs <- listenAndServe(metrics, "metrics") }()
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+	writeHistory := func() {
+		if err := state.saveHistory(historyFile, targets.keyIds()); err != nil {
+			slog.Error("history file could not be written", "file", historyFile, "error", err)
+		}
+	}
+
+	for {
+		select {
+		case status := <-res:
+			if !targets.active(status.Target.Id) {
+				continue
+			}
+			state.Update(status)
+			updateServices(status.Target.Id, state, config)
+			if c := statsd.Load(); c != nil {
+				c.status(status)
+			}
+			if unchecked != nil {
+				delete(unchecked, status.Target.Id)
+				if len(unchecked) == 0 {
+					unchecked = nil
+					go startupSummary(state, config)
+				}
+			}
+			if db != nil {
+				if err := db.record(status); err != nil {
+					targetLog(status.Target).Error("database write error", "event", "db_error", "error", err)
+				}
+			}
+		case sig := <-sigs:
+			if sig == syscall.SIGHUP {
+				reloadConfig(filename, targets, state)
+				config = targets.config
+				setServices(ctx, &wg, config.Services, config)
+				continue
+			}
+			if historyFile != "" {
+				writeHistory()
+			}
+			return shutdown("signal "+sig.String(), cancel, &wg, config)
+		case err := <-serverErrs:
+			if historyFile != "" {
+				writeHistory()
+			}
+			shutdown(err.Error(), cancel, &wg, config)
+			return err
+		case reply := <-reloads:
+			diff, err := reloadConfig(filename, targets, state)
+			config = targets.config
+			setServices(ctx, &wg, config.Services, config)
+			reply <- reloadResult{diff, err}
+		case <-saveHistory:
+			writeHistory()
+		}
+	}
+}
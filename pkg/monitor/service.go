@@ -0,0 +1,205 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A logical service made of several targets, e.g. the nodes behind an
+// API. It is up while at least Quorum of its members are up, and alerts
+// on its own transitions instead of each member going down or up.
+type ServiceGroup struct {
+	Name string
+	// member target ids, their position in the config
+	Targets []int
+	// members that must be up, defaults to all of them
+	Quorum int
+	// also send the members' own down and up alerts
+	MemberAlerts bool
+}
+
+// Aggregate status of a ServiceGroup, served on /services.
+type ServiceStatus struct {
+	Name   string
+	Online bool
+	Since  time.Time
+	// members up, members and how many must be up
+	Up      int
+	Members int
+	Quorum  int
+	// member target ids
+	Targets []int
+	// alerts are sent as from a target of this name and a negative id,
+	// through their own alert routine
+	target *Target
+	alerts chan TargetStatus
+	cancel context.CancelFunc
+}
+
+// service alerts queued while the alert routine is busy
+const serviceAlertQueue = 8
+
+// Services of the running config, updated from the serve loop as member
+// statuses come in.
+var services = struct {
+	sync.Mutex
+	groups []*ServiceStatus
+	// service by member id, for members without MemberAlerts
+	muted map[int]string
+	// id of the last service added, counting down from -1
+	lastId int
+	// settings outside Targets and Services the alert routines run with
+	config Config
+}{muted: make(map[int]string)}
+
+// Use the services of a (re)loaded config, services kept by name carry
+// on with their state. Their alert routines run until ctx is done, and
+// restart when the config changed outside Targets and Services.
+func setServices(ctx context.Context, wg *sync.WaitGroup, groups []ServiceGroup, config Config) {
+	services.Lock()
+	defer services.Unlock()
+	global := config
+	global.Targets, global.Services = nil, nil
+	restart := !reflect.DeepEqual(global, services.config)
+	services.config = global
+
+	old := make(map[string]*ServiceStatus)
+	for _, s := range services.groups {
+		old[s.Name] = s
+	}
+	services.groups = nil
+	services.muted = make(map[int]string)
+	for _, g := range groups {
+		s, ok := old[g.Name]
+		if !ok {
+			services.lastId--
+			s = &ServiceStatus{Name: g.Name, Online: true, Since: time.Now(), target: &Target{Id: services.lastId, Name: "service " + g.Name}}
+		}
+		delete(old, g.Name)
+		if !ok || restart {
+			s.startAlerts(ctx, wg, config)
+		}
+		s.Targets = g.Targets
+		s.Members = len(g.Targets)
+		s.Quorum = g.Quorum
+		if s.Quorum == 0 {
+			s.Quorum = s.Members
+		}
+		services.groups = append(services.groups, s)
+		for _, id := range g.Targets {
+			if !g.MemberAlerts {
+				services.muted[id] = g.Name
+			}
+		}
+	}
+	// removed
+	for _, s := range old {
+		s.cancel()
+	}
+}
+
+// (Re)start the routine sending the service's alerts, see alertRoutine.
+func (s *ServiceStatus) startAlerts(ctx context.Context, wg *sync.WaitGroup, config Config) {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.alerts = make(chan TargetStatus, serviceAlertQueue)
+	wg.Add(1)
+	go func(alerts <-chan TargetStatus) {
+		defer wg.Done()
+		alertRoutine(ctx, alerts, nil, config)
+	}(s.alerts)
+}
+
+// The service alerting for this target instead of it, if any.
+func mutedBy(id int) string {
+	services.Lock()
+	defer services.Unlock()
+	return services.muted[id]
+}
+
+// Latest status of every service, in config order.
+func serviceSnapshot() []ServiceStatus {
+	services.Lock()
+	defer services.Unlock()
+	statuses := make([]ServiceStatus, len(services.groups))
+	for i, s := range services.groups {
+		statuses[i] = *s
+	}
+	return statuses
+}
+
+// Recount the services the target with this id is a member of, alerting
+// those which went down or came back up. A service is only counted once
+// all its members have been checked.
+func updateServices(id int, state *State, config Config) {
+	services.Lock()
+	defer services.Unlock()
+	for _, s := range services.groups {
+		member := false
+		for _, m := range s.Targets {
+			member = member || m == id
+		}
+		if !member {
+			continue
+		}
+
+		up, checked := 0, 0
+		var down []string
+		for _, m := range s.Targets {
+			status, ok := state.Get(m)
+			if !ok {
+				continue
+			}
+			checked++
+			if status.Online {
+				up++
+			} else {
+				down = append(down, status.Target.Name)
+			}
+		}
+		if checked < len(s.Targets) {
+			continue
+		}
+		s.Up = up
+		online := up >= s.Quorum
+		if online == s.Online {
+			continue
+		}
+		s.Online = online
+		s.Since = time.Now()
+
+		msg := fmt.Sprintf("%d of %d members up, %d needed", up, s.Members, s.Quorum)
+		if len(down) > 0 {
+			msg += ", down: " + strings.Join(down, ", ")
+		}
+		if online {
+			slog.Info("service up", "event", "service_up", "service", s.Name, "up", up, "quorum", s.Quorum)
+		} else {
+			slog.Warn("service down", "event", "service_down", "service", s.Name, "up", up, "quorum", s.Quorum, "down", strings.Join(down, ", "))
+		}
+		status := TargetStatus{Target: s.target, Online: online, State: "up", Since: s.Since, LastCheck: s.Since, ErrorMsg: msg}
+		if !online {
+			status.State = "down"
+		}
+		status.Maintenance = inMaintenance(s.Since, config.Maintenance)
+		// called from the serve loop, which mustn't wait for the alert
+		select {
+		case s.alerts <- status:
+		default:
+			targetLog(s.target).Error("service alert dropped, too many queued", "event", "alert_failed", "service", s.Name)
+		}
+	}
+}
+
+// GET /services, the aggregate status of every service.
+func servicesHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, serviceSnapshot())
+}
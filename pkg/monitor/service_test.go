@@ -0,0 +1,55 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServiceAlertsPerService(t *testing.T) {
+	captureLog(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+		setServices(context.Background(), &wg, nil, Config{})
+	}()
+	config := Config{Timeout: 5}
+	groups := []ServiceGroup{{Name: "api", Targets: []int{1}}, {Name: "db", Targets: []int{2}}}
+	setServices(ctx, &wg, groups, config)
+
+	services.Lock()
+	api, db := services.groups[0].target, services.groups[1].target
+	services.Unlock()
+	if api.Id >= 0 || db.Id >= 0 || api.Id == db.Id {
+		t.Fatalf("service target ids %d and %d, want distinct negative ones", api.Id, db.Id)
+	}
+
+	// both go down at once, each alerts through its own routine
+	state := NewState()
+	for id := 1; id <= 2; id++ {
+		state.Update(TargetStatus{Target: &Target{Id: id, Name: "member"}, State: "down", LastCheck: time.Now()})
+		updateServices(id, state, config)
+	}
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		alertsSent.Lock()
+		sent := alertsSent.count[api.Id] == 1 && alertsSent.count[db.Id] == 1
+		alertsSent.Unlock()
+		if sent {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("down alerts of both services not sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// a reload keeps the services and their ids
+	setServices(ctx, &wg, groups, config)
+	services.Lock()
+	defer services.Unlock()
+	if services.groups[0].target != api || services.groups[1].target != db || services.groups[0].Online {
+		t.Error("reload lost the services' state")
+	}
+}
@@ -0,0 +1,116 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// seconds to wait for pending alerts on shutdown, when Config.ShutdownTimeout is not set
+const ShutdownTimeout = 15
+
+// Alerts being sent, so shutdown can wait for them.
+type inflight struct {
+	sync.Mutex
+	wg      sync.WaitGroup
+	closing bool
+	// alerts being sent per target id, a realert can overlap a held one
+	count map[int]int
+	names map[int]string
+}
+
+var pending = inflight{count: make(map[int]int), names: make(map[int]string)}
+
+// Accept alerts again, for a new Serve.
+func (p *inflight) open() {
+	p.Lock()
+	p.closing = false
+	p.Unlock()
+}
+
+// Register an alert about to be sent. Returns false once shutdown started.
+func (p *inflight) start(t *Target) bool {
+	p.Lock()
+	defer p.Unlock()
+	if p.closing {
+		return false
+	}
+	p.wg.Add(1)
+	p.count[t.Id]++
+	p.names[t.Id] = t.Name
+	return true
+}
+
+func (p *inflight) done(t *Target) {
+	p.Lock()
+	if p.count[t.Id]--; p.count[t.Id] <= 0 {
+		delete(p.count, t.Id)
+		delete(p.names, t.Id)
+	}
+	p.Unlock()
+	p.wg.Done()
+}
+
+// Names of targets with alerts still pending, sorted.
+func (p *inflight) abandoned() []string {
+	p.Lock()
+	defer p.Unlock()
+	var names []string
+	for _, name := range p.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Cancel all checks and wait for them and any pending alerts to finish,
+// but never longer than config.ShutdownTimeout. Returns an error naming
+// the targets whose alerts were abandoned.
+func shutdown(reason string, cancel context.CancelFunc, wg *sync.WaitGroup, config Config) error {
+	timeout := config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = ShutdownTimeout
+	}
+	slog.Info("shutting down", "event", "shutdown", "reason", reason, "timeout", timeout)
+
+	if !pending.drain(cancel, wg, time.Duration(timeout)*time.Second) {
+		targets := strings.Join(pending.abandoned(), ", ")
+		slog.Error("shutdown timed out, abandoning alerts", "event", "shutdown", "targets", targets)
+		return fmt.Errorf("shutdown timed out, alerts abandoned for %s", targets)
+	}
+	slog.Info("shutdown complete", "event", "shutdown")
+	return nil
+}
+
+// Refuse new alerts, cancel the checks and wait up to timeout for them
+// and the alerts being sent. Reports whether everything finished.
+func (p *inflight) drain(cancel context.CancelFunc, wg *sync.WaitGroup, timeout time.Duration) bool {
+	p.Lock()
+	p.closing = true
+	p.Unlock()
+	cancel()
+	// held for quiet hours, sending them now would page during them
+	if names := dropQuiet(); len(names) > 0 {
+		slog.Warn("alerts held for quiet hours dropped", "event", "shutdown", "targets", strings.Join(names, ", "))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		// don't lose alerts held for a digest
+		digests.flush()
+		wg.Wait()
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
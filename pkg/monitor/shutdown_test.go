@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownTimeoutSlowAlert(t *testing.T) {
+	captureLog(t)
+	defer func() {
+		pending.Lock()
+		pending.closing = false
+		pending.Unlock()
+	}()
+	config := Config{Timeout: 5}
+
+	slow := make(chan bool)
+	go func() {
+		slow <- alert(downStatus(&Target{Id: 1, Name: "slow", Addr: "tcp://slow:1", Commandrun: "sleep 1"}), config)
+	}()
+	// until the alert is registered
+	for len(pending.abandoned()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	_, cancel := context.WithCancel(context.Background())
+	if pending.drain(cancel, &wg, 100*time.Millisecond) {
+		t.Fatal("drain finished with an alert still running")
+	}
+	if waited := time.Since(start); waited > 500*time.Millisecond {
+		t.Errorf("drain waited %s past its timeout", waited)
+	}
+	if names := pending.abandoned(); len(names) != 1 || names[0] != "slow" {
+		t.Errorf("abandoned %v, want the slow target", names)
+	}
+	if alert(downStatus(&Target{Id: 2, Name: "late", Addr: "tcp://late:1", Commandrun: "true"}), config) != true || len(pending.abandoned()) != 1 {
+		t.Error("alert accepted once shutting down")
+	}
+
+	<-slow
+	if !pending.drain(cancel, &wg, time.Second) {
+		t.Error("drain didn't finish once the alert was sent")
+	}
+}
+
+func TestShutdownOverlappingAlerts(t *testing.T) {
+	p := inflight{count: make(map[int]int), names: make(map[int]string)}
+	target := &Target{Id: 1, Name: "web"}
+	// a realert racing the standoff flush
+	p.start(target)
+	p.start(target)
+	p.done(target)
+	if names := p.abandoned(); !reflect.DeepEqual(names, []string{"web"}) {
+		t.Errorf("abandoned %v with one alert still sending", names)
+	}
+	p.done(target)
+	if names := p.abandoned(); len(names) != 0 {
+		t.Errorf("abandoned %v once both were sent", names)
+	}
+}
+
+func TestShutdownLogsQuietHeld(t *testing.T) {
+	buf := captureLog(t)
+	now := time.Now()
+	config := Config{QuietHours: &Window{Start: now.Add(-time.Minute).Format("15:04"), End: now.Add(2 * time.Minute).Format("15:04")}}
+	target := &Target{Id: 1, Name: "night", RespectQuietHours: true}
+	if !quietHold(*downStatus(target), config) {
+		t.Fatal("alert not held during quiet hours")
+	}
+
+	p := inflight{count: make(map[int]int), names: make(map[int]string)}
+	_, cancel := context.WithCancel(context.Background())
+	if !p.drain(cancel, &sync.WaitGroup{}, time.Second) {
+		t.Fatal("drain timed out")
+	}
+	if !strings.Contains(buf.String(), `msg="alerts held for quiet hours dropped" event=shutdown targets=night`) {
+		t.Errorf("held alert not logged:\n%s", buf)
+	}
+	if len(dropQuiet()) != 0 {
+		t.Error("held alert kept after shutdown")
+	}
+}
@@ -0,0 +1,34 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Post the status to a Slack incoming webhook.
+func SlackAlert(status TargetStatus, config Config) error {
+	down := time.Since(status.Since).Round(time.Second)
+	var text string
+	switch {
+	case status.Online && status.CertWarning:
+		text = fmt.Sprintf(":warning: *%s* (%s): %s", status.Target.Name, status.Target.Addr, status.ErrorMsg)
+	case status.Online && status.ContentChanged:
+		text = fmt.Sprintf(":pencil2: *%s* (%s): %s", status.Target.Name, status.Target.Addr, status.ErrorMsg)
+	case status.Online && status.degraded:
+		text = fmt.Sprintf(":snail: *%s* (%s) is degraded: %s", status.Target.Name, status.Target.Addr, status.ErrorMsg)
+	case status.Online:
+		text = fmt.Sprintf(":white_check_mark: *%s* (%s) is back up, was down for %s", status.Target.Name, status.Target.Addr, down)
+	default:
+		text = fmt.Sprintf(":red_circle: *%s* (%s) is DOWN for %s: %s", status.Target.Name, status.Target.Addr, down, status.ErrorMsg)
+	}
+
+	if status.FailureBody != "" {
+		text += fmt.Sprintf("\n```%s```", status.FailureBody)
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	return postAlert("Slack", config.Alert.SlackWebhook, "application/json", nil, body, config)
+}
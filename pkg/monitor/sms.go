@@ -0,0 +1,44 @@
+package monitor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const twilioURL = "https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json"
+
+// Text the status to every Alert.Twilio recipient. The auth token only
+// goes into the Authorization header, never into errors or logs.
+func SMSAlert(status TargetStatus, config Config) error {
+	twilio := config.Alert.Twilio
+	state := "DOWN"
+	if status.Online {
+		state = "UP"
+	}
+	text := fmt.Sprintf("%s %s", status.Target.Name, state)
+	if !status.Online && status.ErrorMsg != "" {
+		text += ": " + status.ErrorMsg
+	}
+	// keep it to a few SMS segments
+	if len(text) > 300 {
+		text = text[:297] + "..."
+	}
+
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(twilio.AccountSID+":"+twilio.AuthToken))
+	headers := map[string]string{"Authorization": auth}
+	endpoint := fmt.Sprintf(twilioURL, url.PathEscape(twilio.AccountSID))
+
+	var failed []string
+	for _, to := range twilio.To {
+		form := url.Values{"From": {twilio.From}, "To": {to}, "Body": {text}}
+		if err := postAlert("SMS", endpoint, "application/x-www-form-urlencoded", headers, []byte(form.Encode()), config); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", to, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
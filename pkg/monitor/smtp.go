@@ -0,0 +1,110 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/go-gomail/gomail"
+)
+
+// Seconds to connect to the SMTP server
+const SMTPTimeout = 10
+
+// CAs trusted for the SMTP server's certificate, the system ones when nil
+var smtpRootCAs *x509.CertPool
+
+// Connect to the SMTP server, encrypt the connection as SMTP.Security
+// asks and authenticate.
+func dialSMTP(hostname string, port int, conf SMTPConfig) (*smtp.Client, error) {
+	security := conf.Security
+	if security == "" && port == 465 {
+		security = "tls"
+	}
+	addr := net.JoinHostPort(hostname, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: hostname, RootCAs: smtpRootCAs}
+	dialer := &net.Dialer{Timeout: SMTPTimeout * time.Second}
+
+	var conn net.Conn
+	var err error
+	if security == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c, err := smtp.NewClient(conn, hostname)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// without a mode the connection is upgraded whenever the server offers it
+	if security == "starttls" || security == "" {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			err = c.StartTLS(tlsConfig)
+		} else if security == "starttls" {
+			err = errors.New("SMTP server doesn't offer STARTTLS")
+		}
+	}
+	if err == nil && conf.User != "" {
+		// PlainAuth refuses to send the password unencrypted
+		err = c.Auth(smtp.PlainAuth("", conf.User, conf.Password, hostname))
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Send msg through a new connection to the SMTP server.
+func sendSMTP(hostname string, port int, conf SMTPConfig, msg *gomail.Message) error {
+	c, err := dialSMTP(hostname, port, conf)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// gomail.Send flattens errors into text, keep the SMTP reply so a
+	// rejection isn't retried
+	var smtpErr error
+	send := gomail.SendFunc(func(from string, to []string, msg io.WriterTo) error {
+		smtpErr = deliver(c, from, to, msg)
+		return smtpErr
+	})
+	if err := gomail.Send(send, msg); err != nil {
+		if smtpErr != nil {
+			return smtpErr
+		}
+		return err
+	}
+	return c.Quit()
+}
+
+func deliver(c *smtp.Client, from string, to []string, msg io.WriterTo) error {
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := msg.WriteTo(w); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+type EndpointStatus struct {
+	// host:port of the endpoint, as advertised by the SRV record
+	Addr     string
+	Priority uint16
+	Weight   uint16
+	Online   bool
+	ErrorMsg string
+}
+
+// Resolve the SRV record name e.g. "_service._tcp.example.com" through r,
+// the system resolver when nil. Records are returned sorted by priority and
+// randomized by weight within a priority.
+func ResolveSRV(ctx context.Context, r *net.Resolver, name string) ([]*net.SRV, error) {
+	_, addrs, err := r.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no SRV records for %s", name)
+	}
+	return addrs, nil
+}
+
+// Check each SRV endpoint with a TCP connect through dialer. The service is healthy if at
+// least quorum endpoints are up; a quorum of 0 requires all of them. The
+// endpoints are dialed at once, so a blackholed one can't use up the ctx
+// deadline of the others.
+func CheckSRV(ctx context.Context, dialer *net.Dialer, addrs []*net.SRV, quorum int) (endpoints []EndpointStatus, ok bool, msg string) {
+	endpoints = make([]EndpointStatus, len(addrs))
+	var wg sync.WaitGroup
+	for i, srv := range addrs {
+		endpoints[i] = EndpointStatus{
+			Addr:     net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), fmt.Sprintf("%d", srv.Port)),
+			Priority: srv.Priority,
+			Weight:   srv.Weight,
+		}
+		wg.Add(1)
+		go func(ep *EndpointStatus) {
+			defer wg.Done()
+			conn, err := dialer.DialContext(ctx, "tcp", ep.Addr)
+			if err != nil {
+				ep.ErrorMsg = fmt.Sprintf("%s", err)
+				return
+			}
+			conn.Close()
+			ep.Online = true
+		}(&endpoints[i])
+	}
+	wg.Wait()
+
+	up := 0
+	var failed []string
+	for _, ep := range endpoints {
+		if ep.Online {
+			up++
+		} else {
+			failed = append(failed, ep.Addr)
+		}
+	}
+	if quorum <= 0 || quorum > len(addrs) {
+		quorum = len(addrs)
+	}
+	ok = up >= quorum
+	if !ok {
+		msg = fmt.Sprintf("%d/%d endpoints up, quorum %d, down: %s", up, len(addrs), quorum, strings.Join(failed, ", "))
+	}
+	return endpoints, ok, msg
+}
@@ -0,0 +1,39 @@
+package monitor
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCheckSRVBlackholedEndpoint(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := uint16(ln.Addr().(*net.TCPAddr).Port)
+
+	// 100::/64 is the IPv6 discard prefix, a connect hangs until the
+	// deadline or fails right away without an IPv6 route
+	addrs := []*net.SRV{
+		{Target: "100::1.", Port: 80},
+		{Target: "127.0.0.1.", Port: port},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	endpoints, ok, msg := CheckSRV(ctx, &net.Dialer{}, addrs, 1)
+	if !ok {
+		t.Fatalf("quorum 1 not met with a listening endpoint: %s", msg)
+	}
+	if endpoints[0].Online || !endpoints[1].Online {
+		t.Errorf("endpoints = %+v, want only the listening one up", endpoints)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, ok, _ := CheckSRV(ctx, &net.Dialer{}, addrs, 0); ok {
+		t.Error("quorum of all met with a blackholed endpoint")
+	}
+}
@@ -0,0 +1,57 @@
+package monitor
+
+import "time"
+
+// Decides which alert requests of a target get sent. A fresh down alert
+// is held for the standoff delay and dropped if the target comes back
+// meanwhile, so brief outages don't alert at all.
+type standoff struct {
+	delay time.Duration
+	// down alert waiting for the delay to pass
+	held   *TargetStatus
+	heldAt time.Time
+}
+
+// Handle an alert request made at now. Returns the alert to send right
+// away, if any.
+func (s *standoff) request(req *TargetStatus, now time.Time) *TargetStatus {
+	if s.held == nil {
+		if req.Online || now.Sub(req.Since) > s.delay {
+			return req
+		}
+		// down for less than the standoff, wait and see
+		s.held, s.heldAt = req, now
+		return nil
+	}
+
+	if !req.Online {
+		// still down, the held alert stands
+		return nil
+	}
+	s.held = nil
+	// Don't bother with 'up' alert if the host was down less than standoff time
+	if now.Sub(req.Since) > s.delay {
+		return req
+	}
+	targetLog(req.Target).Debug("down/up alerts skipped due to standoff", "event", "alert_skipped")
+	req.Since = now
+	return nil
+}
+
+// When the held alert is due, zero if there is none.
+func (s *standoff) deadline() time.Time {
+	if s.held == nil {
+		return time.Time{}
+	}
+	return s.heldAt.Add(s.delay)
+}
+
+// The held alert, once it is due at now.
+func (s *standoff) due(now time.Time) *TargetStatus {
+	if s.held == nil || now.Before(s.deadline()) {
+		return nil
+	}
+	req := s.held
+	s.held = nil
+	return req
+}
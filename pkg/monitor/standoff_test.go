@@ -0,0 +1,128 @@
+package monitor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var standoffStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func standoffReq(online bool, since time.Time) *TargetStatus {
+	return &TargetStatus{Target: &Target{Name: "t"}, Online: online, Since: since}
+}
+
+func TestStandoffBriefOutage(t *testing.T) {
+	s := standoff{delay: time.Minute}
+	down := standoffStart
+	if send := s.request(standoffReq(false, down), down); send != nil {
+		t.Fatal("down alert sent before the standoff passed")
+	}
+	up := down.Add(30 * time.Second)
+	if send := s.request(standoffReq(true, down), up); send != nil {
+		t.Fatal("up alert sent for an outage shorter than the standoff")
+	}
+	if send := s.due(down.Add(2 * time.Minute)); send != nil {
+		t.Fatal("held down alert sent after the target came back")
+	}
+	if !s.deadline().IsZero() {
+		t.Fatal("alert still held after the target came back")
+	}
+}
+
+func TestStandoffLongOutage(t *testing.T) {
+	s := standoff{delay: time.Minute}
+	down := standoffStart
+	if send := s.request(standoffReq(false, down), down); send != nil {
+		t.Fatal("down alert sent before the standoff passed")
+	}
+	if want := down.Add(time.Minute); !s.deadline().Equal(want) {
+		t.Fatalf("deadline %s, want %s", s.deadline(), want)
+	}
+	if send := s.due(down.Add(59 * time.Second)); send != nil {
+		t.Fatal("down alert sent before its deadline")
+	}
+	if send := s.due(down.Add(time.Minute)); send == nil || send.Online {
+		t.Fatal("down alert not sent once the standoff passed")
+	}
+	if send := s.request(standoffReq(true, down), down.Add(5*time.Minute)); send == nil || !send.Online {
+		t.Fatal("up alert not sent after an alerted outage")
+	}
+}
+
+func TestStandoffRepeatedDowns(t *testing.T) {
+	s := standoff{delay: time.Minute}
+	down := standoffStart
+	sent := 0
+	for i := 0; i < 5; i++ {
+		if s.request(standoffReq(false, down), down.Add(time.Duration(i)*10*time.Second)) != nil {
+			sent++
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if s.due(down.Add(time.Duration(i+1)*time.Minute)) != nil {
+			sent++
+		}
+	}
+	if sent != 1 {
+		t.Fatalf("%d down alerts sent, want 1", sent)
+	}
+}
+
+func TestStandoffNoDelay(t *testing.T) {
+	s := standoff{}
+	down := standoffStart
+	if send := s.request(standoffReq(false, down), down.Add(time.Second)); send == nil {
+		t.Fatal("down alert held without a standoff")
+	}
+}
+
+// Run with -race: checks go on while the alert routine holds a down alert.
+func TestStandoffHeldAlertIsACopy(t *testing.T) {
+	captureLog(t)
+	out := filepath.Join(t.TempDir(), "out")
+	target := &Target{Id: 1, Name: "web", Addr: "tcp://web:80", Commandrun: "echo {{.ErrorMsg}} > " + out}
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	alertRequest, alerted := make(chan TargetStatus, 1), make(chan time.Time, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		alertRoutine(ctx, alertRequest, alerted, Config{Timeout: 5, Standoff: 1})
+	}()
+
+	status := downStatus(target)
+	status.ErrorMsg = "first"
+	requestAlert(ctx, alertRequest, *status)
+	var lastAlert time.Time
+	for deadline := time.Now().Add(3 * time.Second); lastAlert.IsZero() && time.Now().Before(deadline); {
+		// the next checks rewrite their status
+		status.ErrorMsg = "later"
+		status.CertWarning = !status.CertWarning
+		status.LastCheck = time.Now()
+		select {
+		case lastAlert = <-alerted:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if lastAlert.IsZero() {
+		t.Fatal("held alert not sent after the standoff")
+	}
+	if !status.LastAlert.IsZero() {
+		t.Error("alert routine wrote the check's status")
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "first\n" {
+		t.Errorf("held alert sent with %q, want the status it was requested with", got)
+	}
+}
@@ -0,0 +1,262 @@
+package monitor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// samples kept per target when Config.HistorySize is not set
+const HistorySize = 100
+
+// error messages kept per target when Config.ErrorHistory is not set
+const ErrorHistory = 10
+
+// State holds the latest status of every target. The status server,
+// metrics, digests and reloads all read from it, it is safe for
+// concurrent use.
+type State struct {
+	mu sync.Mutex
+	// latest status per target
+	latest map[*Target]TargetStatus
+	// rolling check history, keyed by target id
+	history map[int][]Sample
+	// samples kept per target
+	HistorySize int
+	// samples older than this are pruned, 0 keeps them regardless of age
+	HistoryMaxAge time.Duration
+	// uptime per target id, over UptimeWindows
+	uptime        map[int]uptimeTracker
+	UptimeWindows []int
+	// latest error messages per target id, oldest first
+	errors map[int][]TimedError
+	// error messages kept per target
+	ErrorHistory int
+}
+
+// An error message and the check it came from.
+type TimedError struct {
+	Time     time.Time
+	ErrorMsg string
+}
+
+// Sample is the outcome of a single check.
+type Sample struct {
+	Time     time.Time
+	Online   bool
+	Latency  time.Duration
+	ErrorMsg string `json:",omitempty"`
+}
+
+func NewState() *State {
+	s := new(State)
+	s.latest = make(map[*Target]TargetStatus)
+	s.history = make(map[int][]Sample)
+	s.HistorySize = HistorySize
+	s.uptime = make(map[int]uptimeTracker)
+	s.errors = make(map[int][]TimedError)
+	s.ErrorHistory = ErrorHistory
+	s.UptimeWindows = UptimeWindows
+	return s
+}
+
+// Apply the history settings of a (re)loaded config, the defaults for
+// those not set.
+func (s *State) configure(config Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.HistorySize = HistorySize
+	if config.HistorySize > 0 {
+		s.HistorySize = config.HistorySize
+	}
+	s.HistoryMaxAge = time.Duration(config.HistoryMaxAge) * time.Second
+	s.ErrorHistory = ErrorHistory
+	if config.ErrorHistory > 0 {
+		s.ErrorHistory = config.ErrorHistory
+	}
+}
+
+// Store the latest status of a target, along with its history and uptime.
+func (s *State) Update(status TargetStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := status.Target.Id
+	if s.uptime[id] == nil {
+		s.uptime[id] = newUptimeTracker(s.UptimeWindows)
+	}
+	s.uptime[id].add(status.LastCheck, status.Online)
+	status.Uptime = s.uptime[id].percentages(status.LastCheck)
+
+	if status.ErrorMsg != "" {
+		errs := append(s.errors[id], TimedError{Time: status.LastCheck, ErrorMsg: status.ErrorMsg})
+		if len(errs) > s.ErrorHistory {
+			// copy, so the dropped messages can be released
+			errs = append([]TimedError(nil), errs[len(errs)-s.ErrorHistory:]...)
+		}
+		s.errors[id] = errs
+	}
+	status.Errors = s.errors[id]
+
+	// a reloaded target runs with a new *Target, drop the old one
+	for t := range s.latest {
+		if t.Id == id && t != status.Target {
+			delete(s.latest, t)
+		}
+	}
+	s.latest[status.Target] = status
+	s.addSample(status)
+}
+
+// Record a check result in the target's history. Caller must hold the lock.
+func (s *State) addSample(status TargetStatus) {
+	id := status.Target.Id
+	s.history[id] = append(s.history[id], Sample{
+		Time:     status.LastCheck,
+		Online:   status.Online,
+		Latency:  status.Latency,
+		ErrorMsg: status.ErrorMsg,
+	})
+	s.history[id] = s.trim(s.history[id])
+}
+
+// Drop samples beyond the configured size and age.
+func (s *State) trim(samples []Sample) []Sample {
+	if s.HistoryMaxAge > 0 {
+		cutoff := time.Now().Add(-s.HistoryMaxAge)
+		i := 0
+		for i < len(samples) && samples[i].Time.Before(cutoff) {
+			i++
+		}
+		samples = samples[i:]
+	}
+	if len(samples) > s.HistorySize {
+		samples = samples[len(samples)-s.HistorySize:]
+	}
+	// copy, so the backing array of dropped samples can be released
+	return append([]Sample(nil), samples...)
+}
+
+// Load history persisted by saveHistory, trimmed to the current settings.
+// ids maps target keys to the ids of the targets now, the history of
+// targets no longer in the config is dropped, the uptime of the others
+// starts from their loaded samples. A missing file is not an error.
+func (s *State) loadHistory(filename string, ids map[string]int) error {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	history := make(map[string][]Sample)
+	if err := json.NewDecoder(file).Decode(&history); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, samples := range history {
+		id, ok := ids[key]
+		if !ok {
+			continue
+		}
+		s.history[id] = s.trim(samples)
+		if s.uptime[id] == nil {
+			s.uptime[id] = newUptimeTracker(s.UptimeWindows)
+		}
+		for _, sample := range s.history[id] {
+			s.uptime[id].add(sample.Time, sample.Online)
+		}
+	}
+	return nil
+}
+
+// Write history to filename, via a temporary file so a crash mid-write
+// doesn't lose the previous copy. The history is keyed by target key
+// rather than id, ids are positions in the config and change with it.
+func (s *State) saveHistory(filename string, ids map[string]int) error {
+	s.mu.Lock()
+	history := make(map[string][]Sample, len(ids))
+	for key, id := range ids {
+		if samples, ok := s.history[id]; ok {
+			history[key] = samples
+		}
+	}
+	data, err := json.Marshal(history)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// Change the target's latest status until its next check reports, e.g.
+// after disabling it. Returns false for unknown targets.
+func (s *State) modify(id int, change func(*TargetStatus)) (TargetStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, status := range s.latest {
+		if t.Id == id {
+			change(&status)
+			s.latest[t] = status
+			return status, true
+		}
+	}
+	return TargetStatus{}, false
+}
+
+// Latest status of the target with the given id.
+func (s *State) Get(id int) (TargetStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, status := range s.latest {
+		if t.Id == id {
+			return status, true
+		}
+	}
+	return TargetStatus{}, false
+}
+
+// Check history of the target with the given id, oldest first.
+func (s *State) History(id int) ([]Sample, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	samples, ok := s.history[id]
+	return append([]Sample(nil), samples...), ok
+}
+
+// Latest status of every target, ordered by id.
+func (s *State) Snapshot() []TargetStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]TargetStatus, 0, len(s.latest))
+	for _, status := range s.latest {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Target.Id < statuses[j].Target.Id })
+	return statuses
+}
+
+// Forget a target removed from the config.
+func (s *State) remove(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t := range s.latest {
+		if t.Id == id {
+			delete(s.latest, t)
+		}
+	}
+	delete(s.history, id)
+	delete(s.uptime, id)
+	delete(s.errors, id)
+}
@@ -0,0 +1,89 @@
+package monitor
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run with -race: the serve loop updates while the status page, metrics
+// and digests read.
+func TestStateConcurrentUpdateSnapshot(t *testing.T) {
+	state := NewState()
+	targets := make([]*Target, 4)
+	for i := range targets {
+		targets[i] = &Target{Id: i, Name: "t", Addr: "127.0.0.1"}
+	}
+
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target *Target) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				state.Update(TargetStatus{Target: target, Online: i%2 == 0, ErrorMsg: "x", LastCheck: time.Now()})
+			}
+		}(target)
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				for _, status := range state.Snapshot() {
+					_ = status.Errors
+					_ = status.Uptime
+				}
+				state.Get(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	statuses := state.Snapshot()
+	if len(statuses) != len(targets) {
+		t.Fatalf("got %d statuses, want %d", len(statuses), len(targets))
+	}
+	for i, status := range statuses {
+		if status.Target.Id != i {
+			t.Errorf("snapshot not ordered by id: %d at %d", status.Target.Id, i)
+		}
+	}
+}
+
+func TestHistoryFollowsTargetsAcrossReorder(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history.json")
+	before := []Target{{Id: 1, Name: "web", Addr: "http://web"}, {Id: 2, Name: "db", Addr: "tcp://db:5432"}, {Id: 3, Name: "old", Addr: "tcp://old:1"}}
+	state := NewState()
+	for i := range before {
+		state.Update(TargetStatus{Target: &before[i], Online: true, Latency: time.Millisecond, ErrorMsg: before[i].Name, LastCheck: time.Now()})
+	}
+	if err := state.saveHistory(file, targetIds(before)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a target added in front, one removed
+	after := []Target{{Id: 1, Name: "new", Addr: "http://new"}, {Id: 2, Name: "db", Addr: "tcp://db:5432"}, {Id: 3, Name: "web", Addr: "http://web"}}
+	loaded := NewState()
+	if err := loaded.loadHistory(file, targetIds(after)); err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]string{2: "db", 3: "web"}
+	if len(loaded.history) != len(want) {
+		t.Errorf("history of %d targets loaded, want %d", len(loaded.history), len(want))
+	}
+	for id, name := range want {
+		if samples, _ := loaded.History(id); len(samples) != 1 || samples[0].ErrorMsg != name || samples[0].Latency != time.Millisecond {
+			t.Errorf("target %d got history %v, want that of %s", id, samples, name)
+		}
+	}
+
+	// uptime carries on from the loaded samples
+	web := &after[2]
+	status := TargetStatus{Target: web, Online: false, LastCheck: time.Now()}
+	loaded.Update(status)
+	if got, _ := loaded.Get(web.Id); got.Uptime["1h0m0s"] != 50 {
+		t.Errorf("uptime %v after one loaded up sample and one down check, want 50%%", got.Uptime)
+	}
+}
@@ -0,0 +1,100 @@
+package monitor
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Pushes target metrics to a StatsD collector, with DogStatsD tags.
+type statsdClient struct {
+	sync.Mutex
+	addr string
+	// nil once replaced by a reload
+	conn net.Conn
+	// last write failed, to log once per outage of the collector
+	failing bool
+}
+
+// Set when Config.Statsd is, see startStatsd.
+var statsd atomic.Pointer[statsdClient]
+
+// characters that would break DogStatsD tags
+var statsdTagEscaper = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+
+// Send metrics to Config.Statsd from now on, none when it is empty. A
+// reload changing the address replaces the client.
+func startStatsd(config Config) {
+	old := statsd.Load()
+	if old != nil && old.addr == config.Statsd {
+		return
+	}
+	var c *statsdClient
+	if config.Statsd != "" {
+		// a UDP "connection" only fails for an unresolvable address
+		conn, err := net.Dial("udp", config.Statsd)
+		if err != nil {
+			slog.Error("StatsD collector could not be set up", "addr", config.Statsd, "error", err)
+			return
+		}
+		c = &statsdClient{addr: config.Statsd, conn: conn}
+		slog.Info("sending metrics to StatsD", "addr", config.Statsd)
+	}
+	statsd.Store(c)
+	if old != nil {
+		old.Lock()
+		old.conn.Close()
+		old.conn = nil
+		old.Unlock()
+	}
+}
+
+func statsdTags(t *Target) string {
+	tags := []string{fmt.Sprintf("id:%d", t.Id), "name:" + statsdTagEscaper.Replace(t.Name)}
+	keys := make([]string, 0, len(t.Tags))
+	for k := range t.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		tags = append(tags, statsdTagEscaper.Replace(k)+":"+statsdTagEscaper.Replace(t.Tags[k]))
+	}
+	return strings.Join(tags, ",")
+}
+
+// Send metric lines, logging only the first of consecutive failures.
+func (c *statsdClient) send(lines ...string) {
+	c.Lock()
+	defer c.Unlock()
+	if c.conn == nil {
+		return
+	}
+	_, err := c.conn.Write([]byte(strings.Join(lines, "\n")))
+	if err != nil && !c.failing {
+		slog.Warn("StatsD write failed", "event", "statsd_error", "error", err)
+	} else if err == nil && c.failing {
+		slog.Info("StatsD writes working again", "event", "statsd_error")
+	}
+	c.failing = err != nil
+}
+
+// Up/down gauge and latency timing of a check.
+func (c *statsdClient) status(status TargetStatus) {
+	up := 0
+	if status.Online {
+		up = 1
+	}
+	tags := statsdTags(status.Target)
+	c.send(
+		fmt.Sprintf("pingo.target.up:%d|g|#%s", up, tags),
+		fmt.Sprintf("pingo.target.latency:%d|ms|#%s", status.Latency.Milliseconds(), tags),
+	)
+}
+
+func (c *statsdClient) alert(t *Target) {
+	c.send(fmt.Sprintf("pingo.alerts:1|c|#%s", statsdTags(t)))
+}
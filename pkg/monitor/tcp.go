@@ -0,0 +1,41 @@
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Write send to an open connection and read until the reply contains expect,
+// both within timeout. An empty send just reads, e.g. for a banner.
+func sendExpect(conn net.Conn, send string, expect string, deadline time.Time) error {
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	if send != "" {
+		if _, err := conn.Write([]byte(send)); err != nil {
+			return err
+		}
+	}
+	if expect == "" {
+		return nil
+	}
+
+	var reply []byte
+	rb := make([]byte, 4096)
+	for len(reply) < 64*1024 {
+		n, err := conn.Read(rb)
+		reply = append(reply, rb[:n]...)
+		if bytes.Contains(reply, []byte(expect)) {
+			return nil
+		}
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return fmt.Errorf("expected '%s' before the timeout, got %q", expect, reply)
+			}
+			return fmt.Errorf("expected '%s', got %q, %s", expect, reply, err)
+		}
+	}
+	return fmt.Errorf("expected '%s', not in the first %d bytes", expect, len(reply))
+}
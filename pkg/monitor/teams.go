@@ -0,0 +1,121 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Microsoft Teams incoming webhook message
+type teamsCard struct {
+	Type       string         `json:"@type"`
+	Context    string         `json:"@context"`
+	ThemeColor string         `json:"themeColor"`
+	Summary    string         `json:"summary"`
+	Title      string         `json:"title"`
+	Text       string         `json:"text,omitempty"`
+	Sections   []teamsSection `json:"sections,omitempty"`
+}
+
+type teamsSection struct {
+	Facts []chatFact `json:"facts"`
+}
+
+// longest field value Discord accepts
+const discordFieldMax = 1024
+
+// Discord webhook message
+type discordMessage struct {
+	Embeds []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string      `json:"title"`
+	Description string      `json:"description,omitempty"`
+	Color       int         `json:"color"`
+	Fields      []chatField `json:"fields,omitempty"`
+}
+
+type chatFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type chatField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// Title, color and details of an alert for chat cards. The color is RGB,
+// green for up, red for down and orange for warnings while up.
+func chatAlert(status TargetStatus) (title string, color int, facts []chatFact) {
+	down := time.Since(status.Since).Round(time.Second)
+	switch {
+	case status.Online && status.CertWarning:
+		title, color = fmt.Sprintf("%s certificate expires soon", status.Target.Name), 0xEE9933
+	case status.Online && status.ContentChanged:
+		title, color = fmt.Sprintf("%s content changed", status.Target.Name), 0xEE9933
+	case status.Online && status.degraded:
+		title, color = fmt.Sprintf("%s is degraded", status.Target.Name), 0xEE9933
+	case status.Online:
+		title, color = fmt.Sprintf("%s is back up", status.Target.Name), 0x33EE33
+	default:
+		title, color = fmt.Sprintf("%s is DOWN", status.Target.Name), 0xEE3333
+	}
+	facts = []chatFact{{"Target", status.Target.Name}, {"Address", status.Target.Addr}}
+	if status.ErrorMsg != "" {
+		facts = append(facts, chatFact{"Error", status.ErrorMsg})
+	}
+	if status.FailureBody != "" {
+		facts = append(facts, chatFact{"Response body", status.FailureBody})
+	}
+	if status.Online {
+		facts = append(facts, chatFact{"Was down for", down.String()})
+	} else {
+		facts = append(facts, chatFact{"Down for", down.String()})
+	}
+	return title, color, facts
+}
+
+// Post the status to a Microsoft Teams incoming webhook as a MessageCard.
+func TeamsAlert(status TargetStatus, config Config) error {
+	title, color, facts := chatAlert(status)
+	return postTeams(teamsCard{
+		Summary:    title,
+		Title:      title,
+		ThemeColor: fmt.Sprintf("%06X", color),
+		Sections:   []teamsSection{{Facts: facts}},
+	}, config)
+}
+
+// Post the status to a Discord webhook as an embed.
+func DiscordAlert(status TargetStatus, config Config) error {
+	title, color, facts := chatAlert(status)
+	embed := discordEmbed{Title: title, Color: color}
+	for _, f := range facts {
+		if len(f.Value) > discordFieldMax {
+			f.Value = f.Value[:discordFieldMax]
+		}
+		embed.Fields = append(embed.Fields, chatField{Name: f.Name, Value: f.Value, Inline: f.Name != "Error" && f.Name != "Response body"})
+	}
+	return postDiscord(embed, config)
+}
+
+func postTeams(card teamsCard, config Config) error {
+	card.Type = "MessageCard"
+	card.Context = "https://schema.org/extensions"
+	body, err := json.Marshal(card)
+	if err != nil {
+		return err
+	}
+	return postAlert("Teams", config.Alert.TeamsWebhook, "application/json", nil, body, config)
+}
+
+func postDiscord(embed discordEmbed, config Config) error {
+	body, err := json.Marshal(discordMessage{Embeds: []discordEmbed{embed}})
+	if err != nil {
+		return err
+	}
+	return postAlert("Discord", config.Alert.DiscordWebhook, "application/json", nil, body, config)
+}
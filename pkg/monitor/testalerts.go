@@ -0,0 +1,34 @@
+package monitor
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Run a made up down then up alert for every target through the
+// configured notifiers. Reports whether all of them went through.
+func TestAlerts(config Config) bool {
+	limitConcurrency(config)
+	ok := true
+	for i := range config.Targets {
+		t := &config.Targets[i]
+		if t.Addr == "" {
+			continue
+		}
+		now := time.Now()
+		down := TargetStatus{Target: t, Online: false, ErrorMsg: "test alert", Since: now, LastCheck: now}
+		up := TargetStatus{Target: t, Online: true, Since: now, LastCheck: now}
+		for _, status := range []*TargetStatus{&down, &up} {
+			targetLog(t).Info("sending test alert", "event", "test_alert", "online", status.Online)
+			if !alert(status, config) {
+				ok = false
+			}
+		}
+	}
+	if ok {
+		slog.Info("test alerts sent")
+	} else {
+		slog.Error("some test alerts failed")
+	}
+	return ok
+}
@@ -0,0 +1,40 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// Send payload to a UDP service and wait for a reply of at least minBytes.
+// UDP being connectionless, only a reply proves the service is there.
+// addr is resolved by dialer.
+func UDPProbe(ctx context.Context, dialer *net.Dialer, addr string, payload []byte, minBytes int) (int, error) {
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			return 0, err
+		}
+	}
+	if _, err = conn.Write(payload); err != nil {
+		return 0, err
+	}
+
+	rb := make([]byte, 65535)
+	n, err := conn.Read(rb)
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return 0, fmt.Errorf("no reply before the timeout")
+		}
+		return 0, err
+	}
+	if n < minBytes {
+		return n, fmt.Errorf("short reply, got %d bytes, want %d", n, minBytes)
+	}
+	return n, nil
+}
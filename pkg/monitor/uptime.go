@@ -0,0 +1,89 @@
+package monitor
+
+import (
+	"time"
+)
+
+// Uptime windows in seconds when Config.UptimeWindows is not set: 1h, 24h, 30d
+var UptimeWindows = []int{3600, 86400, 30 * 86400}
+
+// buckets per uptime window
+const uptimeBuckets = 60
+
+type uptimeBucket struct {
+	// bucket number, time / bucket width
+	slot  int64
+	up    int
+	total int
+}
+
+// Sliding window uptime, as a ring of fixed width buckets.
+type uptimeRing struct {
+	window  time.Duration
+	width   time.Duration
+	buckets [uptimeBuckets]uptimeBucket
+}
+
+func newUptimeRing(window time.Duration) *uptimeRing {
+	width := window / uptimeBuckets
+	if width <= 0 {
+		width = 1
+	}
+	return &uptimeRing{window: window, width: width}
+}
+
+func (r *uptimeRing) add(t time.Time, online bool) {
+	slot := t.UnixNano() / int64(r.width)
+	b := &r.buckets[slot%uptimeBuckets]
+	if b.slot != slot {
+		*b = uptimeBucket{slot: slot}
+	}
+	b.total++
+	if online {
+		b.up++
+	}
+}
+
+// Percentage of successful checks within the window, -1 without any checks.
+func (r *uptimeRing) percent(now time.Time) float64 {
+	oldest := now.UnixNano()/int64(r.width) - uptimeBuckets
+	up, total := 0, 0
+	for _, b := range r.buckets {
+		if b.slot > oldest {
+			up += b.up
+			total += b.total
+		}
+	}
+	if total == 0 {
+		return -1
+	}
+	return 100 * float64(up) / float64(total)
+}
+
+// Uptime of a target over each of the configured windows.
+type uptimeTracker []*uptimeRing
+
+func newUptimeTracker(windows []int) uptimeTracker {
+	tracker := make(uptimeTracker, 0, len(windows))
+	for _, w := range windows {
+		tracker = append(tracker, newUptimeRing(time.Duration(w)*time.Second))
+	}
+	return tracker
+}
+
+func (u uptimeTracker) add(t time.Time, online bool) {
+	for _, r := range u {
+		r.add(t, online)
+	}
+}
+
+// Uptime percentages keyed by window, e.g. "24h0m0s".
+func (u uptimeTracker) percentages(now time.Time) map[string]float64 {
+	p := make(map[string]float64, len(u))
+	for _, r := range u {
+		if v := r.percent(now); v >= 0 {
+			p[r.window.String()] = v
+		}
+	}
+	return p
+}
@@ -0,0 +1,193 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Init of the Web Page template.
+var tpl = template.Must(
+	template.New("main").Delims("<%", "%>").Funcs(template.FuncMap{"json": json.Marshal}).Parse(`
+	<!DOCTYPE html>
+	<html ng-app="app">
+	<head>
+		<style>
+			body{padding: 40px; color: #33333A; font-family: Arial }
+			table{ border-collapse: collapse}
+			td, th { font-weight: normal; padding: 6px}
+			th{ background-color: #90909D; color: #FFF; border-bottom: 1px solid #445}
+			td{ border-bottom: 1px solid #999;}
+			.online{ background-color: #3E3; color: #FFF; padding: 3px 5px; border-radius: 5px}
+			.offline{ background-color: #E33; color: #FFF; padding: 3px 5px; border-radius: 5px}
+			.warn{ background-color: #E93; color: #FFF; padding: 3px 5px; border-radius: 5px}
+			.maintenance{ background-color: #90909D; color: #FFF; padding: 3px 5px; border-radius: 5px}
+			.time{ font-size: 0.8em }
+		</style>
+	</head>
+	<body>
+		<div id="main" style="margin: auto">
+			<h1>Pingo2</h1>
+			<div id="targets" ng-controller="TargetController">
+				<p>Total number of targets : <strong>{{targets.length}}</strong></p>
+				Search: <input ng-model="q" placeholder="filter keyword">
+				<table>
+					<tr>
+						<th ng-switch="'Target.Name' && asc">
+							<a href ng-click="by = 'Target.Name'; asc=!asc">Name</a>
+							<span ng-switch-when="true">up</span>
+							<span ng-switch-default="false">up</span>
+						</th>
+						<th><a ng-click="by='Target.Addr';asc=!asc">Addr</a></th>
+						<th><a ng-click="by='Online';asc=!asc">Online</a></th>
+						<th><a ng-click="by='Since';asc=!asc">Since</a></th>
+						<th><a ng-click="by='lastCheck';asc=!asc">Last Check</a></th>
+						<th><a ng-click="by='Latency';asc=!asc">Latency</a></th>
+						<th>Message</th>
+					</tr>
+					<tr ng-repeat="t in targets | filter:q |orderBy:by:asc">
+						<td>{{t.Target.Name}}</td>
+						<td>{{t.Target.Addr}}</td>
+						<td ng-switch on="t.Online">
+							<span ng-switch-when="true" ng-class="t.State == 'warn' ? 'warn' : 'online'">{{t.State == 'warn' ? 'slow' : 'online'}}</span>
+							<span ng-switch-when="false" class="offline">{{t.State == 'error' ? 'config error' : 'offline'}}</span>
+							<span ng-if="t.Maintenance" class="maintenance">maintenance</span>
+							<span ng-if="t.Flapping" class="maintenance">flapping</span>
+							<span ng-if="t.Disabled" class="maintenance">disabled</span>
+						</td>
+						<td>{{t.Since | dateFormat}} ({{t.Since | dateFromNow}})</td>
+						<td>{{t.LastCheck | dateFromNow:true}}</td>
+						<td>{{t.Latency / 1000000 | number:0}} ms</td>
+						<td>{{t.ErrorMsg}}</td>
+					</tr>
+				</table>
+			</div>
+		</div>
+		<script src="//cdnjs.cloudflare.com/ajax/libs/jquery/2.1.3/jquery.min.js"></script>
+		<script src="//cdnjs.cloudflare.com/ajax/libs/angular.js/1.3.8/angular.js"></script>
+		<script src="//cdnjs.cloudflare.com/ajax/libs/moment.js/2.8.4/moment.min.js"></script>
+		<script>
+		var app = angular.module('app',[]);
+		app.controller('TargetController', function($scope){
+			$scope.targets = [];
+			<%range .%>
+			$scope.targets.push(<% json . | printf "%s"  %>);
+			<%end%>
+		});
+
+		app.filter('dateFormat', function() {
+				return function(input,format,offset) {
+					var date = moment(new Date(input));
+					if( angular.isDefined(format) ){
+						dateStr = date.format(format);
+					} else {
+						dateStr = date.format('YYYY-MM-DD');
+					}
+					return dateStr;
+				}
+			});
+		// Wrapper around Moment.js fromNow()
+		app.filter('dateFromNow', function() {
+			return function(input, noSuffix) {
+				if( angular.isDefined(input) ) {
+					if( noSuffix ) {
+						return moment(input).fromNow(true);
+					} else {
+						return moment(input).fromNow();
+					}
+				}
+			};
+		});
+		</script>
+	</body>
+	</html>
+	`))
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("HTTP error writing JSON", "error", err)
+	}
+}
+
+// The status server's routes, on a mux of its own so an embedding program
+// keeps http.DefaultServeMux to itself.
+func statusMux(state *State, reloads chan<- chan reloadResult) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", dashboardHandler(state))
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		// browsers get the status page, anything else JSON
+		if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+			// query parameters filter by tag, e.g. ?env=prod
+			tags := make(map[string]string)
+			for k, v := range r.URL.Query() {
+				tags[k] = v[0]
+			}
+			statuses := []TargetStatus{}
+			for _, status := range state.Snapshot() {
+				if status.Target.hasTags(tags) {
+					statuses = append(statuses, status)
+				}
+			}
+			writeJSON(w, statuses)
+			return
+		}
+
+		if err := tpl.Execute(w, state.Snapshot()); err != nil {
+			// most likely the client went away
+			slog.Error("HTTP error writing the status page", "error", err)
+		}
+	})
+	mux.HandleFunc("/targets/", targetsHandler(state))
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/reload", reloadHandler(reloads))
+	mux.HandleFunc("/services", servicesHandler)
+	mux.HandleFunc("/status/", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/status/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		status, ok := state.Get(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, status)
+	})
+
+	mux.HandleFunc("/history/", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/history/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		samples, ok := state.History(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, samples)
+	})
+	return mux
+}
+
+// Serve the status pages until srv fails or is closed.
+func startHttp(srv *http.Server) error {
+	slog.Info("status page available", "url", "http://"+srv.Addr+"/status")
+	return listenAndServe(srv, "HTTP")
+}
+
+// Run srv, a closed server is not an error.
+func listenAndServe(srv *http.Server, name string) error {
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return fmt.Errorf("%s server error, %w", name, err)
+}
@@ -0,0 +1,72 @@
+package monitor
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusMux(t *testing.T) {
+	state := NewState()
+	target := &Target{Id: 1, Name: "web", Addr: "http://web"}
+	state.Update(TargetStatus{Target: target, Online: true, State: "up", LastCheck: time.Now(), Latency: time.Millisecond})
+	// a mux per call, nothing registered on http.DefaultServeMux
+	statusMux(state, nil)
+	mux := statusMux(state, nil)
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/status/1", http.StatusOK},
+		{"/status/2", http.StatusNotFound},
+		{"/history/1", http.StatusOK},
+		{"/history/2", http.StatusNotFound},
+		{"/history/x", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%s: status %d, want %d", tt.path, rec.Code, tt.code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/history/1", nil))
+	var samples []Sample
+	if err := json.NewDecoder(rec.Body).Decode(&samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 || !samples[0].Online || samples[0].Latency != time.Millisecond {
+		t.Errorf("history %+v", samples)
+	}
+	if _, pattern := http.DefaultServeMux.Handler(httptest.NewRequest("GET", "/status", nil)); pattern != "" {
+		t.Errorf("%s registered on http.DefaultServeMux", pattern)
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	captureLog(t)
+	t.Cleanup(pending.open)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- Serve("", ln.Addr().String(), Config{Timeout: 1, ShutdownTimeout: 1}) }()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "HTTP server error") {
+			t.Errorf("got %v serving on a taken address", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve didn't return with its address taken")
+	}
+}
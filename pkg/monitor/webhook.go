@@ -0,0 +1,48 @@
+package monitor
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// JSON document posted by WebhookAlert
+type webhookPayload struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Addr     string `json:"addr"`
+	Online   bool   `json:"online"`
+	State    string `json:"state"`
+	ErrorMsg string `json:"error_msg"`
+	// start of the response body, see Config.CaptureFailureBody
+	FailureBody string    `json:"failure_body,omitempty"`
+	Since       time.Time `json:"since"`
+	LastCheck   time.Time `json:"last_check"`
+	LastAlert   time.Time `json:"last_alert"`
+	// seconds
+	Latency float64 `json:"latency"`
+}
+
+// Post the status as JSON to Alert.WebhookURL.
+func WebhookAlert(status TargetStatus, config Config) error {
+	body, err := json.Marshal(newWebhookPayload(status))
+	if err != nil {
+		return err
+	}
+	return postAlert("webhook", config.Alert.WebhookURL, "application/json", config.Alert.WebhookHeaders, body, config)
+}
+
+func newWebhookPayload(status TargetStatus) webhookPayload {
+	return webhookPayload{
+		Id:          status.Target.Id,
+		Name:        status.Target.Name,
+		Addr:        status.Target.Addr,
+		Online:      status.Online,
+		State:       status.State,
+		ErrorMsg:    status.ErrorMsg,
+		FailureBody: status.FailureBody,
+		Since:       status.Since,
+		LastCheck:   status.LastCheck,
+		LastAlert:   status.LastAlert,
+		Latency:     status.Latency.Seconds(),
+	}
+}
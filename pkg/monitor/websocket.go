@@ -0,0 +1,82 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/websocket"
+)
+
+// returned by the pong handler to stop reading
+var errPong = errors.New("pong")
+
+// Complete the WebSocket upgrade handshake with a ws(s) target and, with
+// ping set, wait for the reply to a ping frame. All by the ctx deadline.
+func checkWebSocket(ctx context.Context, t *Target, addrURL *url.URL, ping bool) error {
+	deadline, _ := ctx.Deadline()
+	serverName := addrURL.Hostname()
+	if t.Host != "" {
+		serverName = t.Host
+	}
+	dialer := websocket.Dialer{
+		NetDialContext:  t.dialer().DialContext,
+		TLSClientConfig: &tls.Config{ServerName: serverName, InsecureSkipVerify: t.InsecureSkipVerify},
+	}
+
+	header := http.Header{}
+	if t.UserAgent != "" {
+		header.Set("User-Agent", t.UserAgent)
+	}
+	for k, v := range t.Headers {
+		header.Set(k, v)
+	}
+	if t.Host != "" {
+		header.Set("Host", t.Host)
+	}
+	if t.Username != "" && t.Password != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(t.Username + ":" + t.Password))
+		header.Set("Authorization", "Basic "+auth)
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, addrURL.String(), header)
+	if errors.Is(err, websocket.ErrBadHandshake) && resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+		return fmt.Errorf("handshake failed, unexpected status %d", resp.StatusCode)
+	} else if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if !ping {
+		return nil
+	}
+
+	if err := conn.WriteControl(websocket.PingMessage, []byte("pingo2"), deadline); err != nil {
+		return err
+	}
+	conn.SetPongHandler(func(string) error { return errPong })
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+	// data messages are skipped, the pong comes back as an error
+	for {
+		_, _, err := conn.NextReader()
+		var closeErr *websocket.CloseError
+		var ne net.Error
+		switch {
+		case err == nil:
+		case errors.Is(err, errPong):
+			return nil
+		case errors.As(err, &closeErr):
+			return fmt.Errorf("connection closed by the server")
+		case errors.As(err, &ne) && ne.Timeout():
+			return fmt.Errorf("no pong before the timeout")
+		default:
+			return err
+		}
+	}
+}
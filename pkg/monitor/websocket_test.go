@@ -0,0 +1,89 @@
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Check the ws URL of an http one once, with a fresh deadline.
+func checkWebSocketOnce(t *Target, addr string, ping bool) error {
+	addrURL, _ := url.Parse("ws" + strings.TrimPrefix(addr, "http"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return checkWebSocket(ctx, t, addrURL, ping)
+}
+
+func TestWebSocket(t *testing.T) {
+	var upgrader websocket.Upgrader
+	headers := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		switch r.URL.Path {
+		case "/chatty":
+			// messages before the pong are skipped
+			conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+		case "/close":
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "bye"))
+			return
+		case "/mute":
+			// no reads, no pong
+			time.Sleep(2 * time.Second)
+			return
+		}
+		// pings are answered while reading
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	target := &Target{Name: "ws", UserAgent: "probe/1.0", Username: "user", Password: "secret", Headers: map[string]string{"X-Probe": "1"}}
+	if err := checkWebSocketOnce(target, srv.URL, false); err != nil {
+		t.Fatalf("handshake: %s", err)
+	}
+	h := <-headers
+	if user, pass, _ := (&http.Request{Header: h}).BasicAuth(); h.Get("User-Agent") != "probe/1.0" || h.Get("X-Probe") != "1" || user != "user" || pass != "secret" {
+		t.Errorf("target headers not sent: %v", h)
+	}
+
+	tests := []struct {
+		path, err string
+	}{
+		{"/", ""},
+		{"/chatty", ""},
+		{"/close", "connection closed by the server"},
+		{"/mute", "no pong before the timeout"},
+	}
+	for _, tt := range tests {
+		err := checkWebSocketOnce(&Target{Name: "ws"}, srv.URL+tt.path, true)
+		<-headers
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: %s", tt.path, err)
+		} else if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%s: got %v, want %s", tt.path, err, tt.err)
+		}
+	}
+}
+
+func TestWebSocketNotUpgraded(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	err := checkWebSocketOnce(&Target{Name: "ws"}, srv.URL, false)
+	if err == nil || err.Error() != "handshake failed, unexpected status 404" {
+		t.Errorf("got %v from a plain HTTP server", err)
+	}
+}